@@ -0,0 +1,101 @@
+package netmagis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind of inconsistency between a host and its aliases, found by CheckAliases.
+type AnomalyKind string
+
+const (
+	// The alias listed by the host does not exist.
+	AnomalyMissingAlias AnomalyKind = "missing-alias"
+	// The alias listed by the host is a host, not an alias.
+	AnomalyNotAnAlias AnomalyKind = "not-an-alias"
+	// The alias listed by the host points to another name.
+	AnomalyWrongTarget AnomalyKind = "wrong-target"
+)
+
+// Inconsistency between the host `Host` and its alias `Alias`.
+type AliasAnomaly struct {
+	Host   string
+	Alias  string
+	Kind   AnomalyKind
+	Detail string
+}
+
+// Check the aliases of the hosts of `domain` declared in the networks the user is
+// allowed to consult, and report the inconsistencies: each alias listed by a host
+// must exist and point back to it. Nothing is modified; the report is meant for a
+// manual repair (e.g. with UpsertAlias or DelHost).
+//
+// This helps recovering from aliases created or removed by other means than this
+// client's AddAlias (e.g. an alias form submitted to the delete endpoint instead of
+// the add endpoint by a faulty script, which can remove a name instead of aliasing
+// it). AddAlias itself always submits to the add endpoint. Hosts removed that way
+// can't be detected, only the aliases left inconsistent.
+func (c *NetmagisClient) CheckAliases(domain string) ([]AliasAnomaly, error) {
+	domain = normalizeFqdn(domain)
+	hosts, err := c.ListHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	anomalies := []AliasAnomaly{}
+	checked := map[string]bool{}
+	for _, host := range hosts {
+		name, _ := host["name"].(string)
+		name = normalizeFqdn(name)
+		if checked[name] || !strings.HasSuffix(name, "."+domain) {
+			continue
+		}
+		checked[name] = true
+
+		aliases, err := c.ListAliasesFor(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range aliases {
+			anomaly, err := c.checkAlias(name, normalizeFqdn(alias))
+			if err != nil {
+				return nil, err
+			}
+			if anomaly != nil {
+				anomalies = append(anomalies, *anomaly)
+			}
+		}
+	}
+	return anomalies, nil
+}
+
+// Check that `alias` exists and points to `host`.
+func (c *NetmagisClient) checkAlias(host string, alias string) (*AliasAnomaly, error) {
+	entry, err := c.Search(alias)
+	if err != nil {
+		return nil, err
+	}
+	anomaly := &AliasAnomaly{Host: host, Alias: alias}
+	if entry == nil {
+		anomaly.Kind = AnomalyMissingAlias
+		anomaly.Detail = fmt.Sprintf("alias '%s' listed by '%s' does not exist", alias, host)
+		return anomaly, nil
+	}
+
+	// The target is unknown when the search result has no name
+	isAlias, _ := entry["is_alias"].(bool)
+	target, _ := entry["name"].(string)
+	switch {
+	case !isAlias:
+		anomaly.Kind = AnomalyNotAnAlias
+		anomaly.Detail = fmt.Sprintf("alias '%s' listed by '%s' is not an alias", alias, host)
+	case !strings.EqualFold(normalizeFqdn(target), host):
+		anomaly.Kind = AnomalyWrongTarget
+		anomaly.Detail = fmt.Sprintf(
+			"alias '%s' listed by '%s' points to '%s'", alias, host, target,
+		)
+	default:
+		return nil, nil
+	}
+	return anomaly, nil
+}
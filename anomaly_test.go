@@ -0,0 +1,25 @@
+package netmagis
+
+import "testing"
+
+func TestCheckAlias(t *testing.T) {
+	tests := map[string]AnomalyKind{
+		"search_alias.html":        "",
+		"search_alias_noname.html": AnomalyWrongTarget,
+		"search_host.html":         AnomalyNotAnAlias,
+		"search_notfound.html":     AnomalyMissingAlias,
+	}
+	for name, expected := range tests {
+		client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": name}))
+		anomaly, err := client.checkAlias("www.example.com", "web.example.com")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		switch {
+		case expected == "" && anomaly != nil:
+			t.Errorf("%s: unexpected anomaly: %v", name, anomaly)
+		case expected != "" && (anomaly == nil || anomaly.Kind != expected):
+			t.Errorf("%s: expected a %s anomaly, got %v", name, expected, anomaly)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package netmagis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// Form fields never passed to the audit handler (anti-CSRF tokens and any secret).
+var auditExcludedFieldRegexp = regexp.MustCompile(`(?i)csrf|xsrf|token|passw|secret`)
+
+// Mutating operation submitted to Netmagis, passed to the handler given with
+// WithAuditHandler.
+type AuditEntry struct {
+	Time time.Time
+	// Form action (e.g. `add-host`)
+	Operation string
+	// Endpoint the form was submitted to
+	Uri string
+	// Form values submitted (anti-CSRF tokens excluded)
+	Form url.Values
+	// Error of the operation (nil on success)
+	Err error
+}
+
+// Pass the submission of `formData` to `uri` and its outcome to the audit handler,
+// if any.
+func (c *NetmagisClient) audit(uri string, formData url.Values, err error) {
+	if c.auditHandler == nil {
+		return
+	}
+	form := url.Values{}
+	for field, values := range formData {
+		if !auditExcludedFieldRegexp.MatchString(field) {
+			form[field] = append([]string(nil), values...)
+		}
+	}
+	c.auditHandler(AuditEntry{
+		Time:      c.clockOrDefault().Now(),
+		Operation: formData.Get("action"),
+		Uri:       uri,
+		Form:      form,
+		Err:       err,
+	})
+}
+
+// Return the form equivalent to the REST `payload` of the operation `action`, for
+// reporting REST operations like form submissions.
+func restForm(action string, payload interface{}) url.Values {
+	form := url.Values{}
+	if action != "" {
+		form.Set("action", action)
+	}
+	fields := map[string]interface{}{}
+	if encoded, err := json.Marshal(payload); err == nil {
+		json.Unmarshal(encoded, &fields)
+	}
+	for field, value := range fields {
+		switch value := value.(type) {
+		case []interface{}:
+			for _, item := range value {
+				form.Add(field, fmt.Sprint(item))
+			}
+		default:
+			form.Set(field, fmt.Sprint(value))
+		}
+	}
+	return form
+}
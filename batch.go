@@ -0,0 +1,133 @@
+package netmagis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Aggregated error of a batch operation, wrapping the error of each failed item
+// (as *ItemError). errors.Is and errors.As look into all of them.
+type MultiError struct {
+	Errors []error
+}
+
+func (error *MultiError) Error() string {
+	messages := []string{}
+	for _, err := range error.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d errors: %s", len(error.Errors), strings.Join(messages, "; "))
+}
+
+func (error *MultiError) Unwrap() []error {
+	return error.Errors
+}
+
+// Error of an item (host) of a batch operation.
+type ItemError struct {
+	Name string
+	Err  error
+}
+
+func (error *ItemError) Error() string {
+	return fmt.Sprintf("%s: %s", error.Name, error.Err.Error())
+}
+
+func (error *ItemError) Unwrap() error {
+	return error.Err
+}
+
+// Result of an item of a batch operation (Err is nil on success).
+type BatchResult struct {
+	Name string
+	Err  error
+}
+
+// Run `operation` on each of `names` (concurrently, see WithMaxConcurrency),
+// continuing past failures, and return the per-item results with a *MultiError
+// aggregating the failures (nil when all succeeded). The operation gets the client
+// to use for the item (see runItem).
+func (c *NetmagisClient) runBatch(names []string, operation func(client *NetmagisClient, idx int, name string) error) ([]BatchResult, error) {
+	errs := c.forEach(len(names), func(idx int) error {
+		return c.runItem(func(client *NetmagisClient) error {
+			return operation(client, idx, names[idx])
+		})
+	})
+
+	results := []BatchResult{}
+	failures := []error{}
+	for idx, name := range names {
+		if errs[idx] != nil {
+			failures = append(failures, &ItemError{Name: name, Err: errs[idx]})
+		}
+		results = append(results, BatchResult{Name: name, Err: errs[idx]})
+	}
+	if len(failures) > 0 {
+		return results, &MultiError{Errors: failures}
+	}
+	return results, nil
+}
+
+// Run the operation of a batch item with a copy of the client bound to the item
+// deadline (see WithBatchItemTimeout). An item abandoned because of its deadline
+// fails with an error of kind ErrTransport wrapping context.DeadlineExceeded.
+func (c *NetmagisClient) runItem(operation func(client *NetmagisClient) error) error {
+	if c.batchItemTimeout <= 0 {
+		return operation(c)
+	}
+	ctx, cancel := context.WithTimeout(c.context(), c.batchItemTimeout)
+	defer cancel()
+
+	err := operation(c.WithContext(ctx))
+	if err != nil && ctx.Err() == context.DeadlineExceeded && c.context().Err() == nil {
+		return &NetmagisError{
+			msg:  fmt.Sprintf("abandoned after %s: %s", c.batchItemTimeout, err.Error()),
+			kind: ErrTransport,
+			err:  context.DeadlineExceeded,
+		}
+	}
+	return err
+}
+
+// Add several hosts, given by their `name`, `ip_address` and AddHost parameters
+// (see Reconcile for the same representation). All the hosts are processed even if
+// some fail.
+func (c *NetmagisClient) AddHosts(hosts []Host) ([]BatchResult, error) {
+	names := []string{}
+	for _, host := range hosts {
+		name, _ := host["name"].(string)
+		names = append(names, name)
+	}
+	return c.runBatch(names, func(client *NetmagisClient, idx int, name string) error {
+		ip, _ := hosts[idx]["ip_address"].(string)
+		if name == "" || ip == "" {
+			return &NetmagisError{msg: "AddHosts: host without name or ip_address"}
+		}
+		params := map[string]interface{}{}
+		for field, value := range hosts[idx] {
+			if !reconcileFields[field] {
+				params[field] = value
+			}
+		}
+		return client.AddHost(name, ip, params)
+	})
+}
+
+// Delete several hosts. All the hosts are processed even if some fail.
+func (c *NetmagisClient) DelHosts(fqdns []string) ([]BatchResult, error) {
+	return c.runBatch(fqdns, func(client *NetmagisClient, idx int, fqdn string) error {
+		return client.DelHost(fqdn)
+	})
+}
+
+// Add the aliases `cnames` pointing to `target`. All the aliases are processed even
+// if some fail (e.g. a name that is not a FQDN).
+func (c *NetmagisClient) AddAliases(target string, cnames []string) ([]BatchResult, error) {
+	return c.runBatch(cnames, func(client *NetmagisClient, idx int, cname string) error {
+		if !checkFqdn(cname) {
+			return &NetmagisError{msg: fmt.Sprintf("AddAliases: alias '%s' is not a FQDN", cname)}
+		}
+		return client.AddAlias(cname, target)
+	})
+}
@@ -0,0 +1,62 @@
+package netmagis
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// Cache of authenticated clients (see NewCachedClient).
+var (
+	clientCacheMutex sync.Mutex
+	clientCache      = map[string]*cachedClient{}
+)
+
+type cachedClient struct {
+	// Held while checking the session and authenticating, so only the callers
+	// for the same URL and username wait for each other
+	mutex        sync.Mutex
+	client       *NetmagisClient
+	passwordHash [32]byte
+}
+
+// Same as NewClient but reuse the client (and its session) previously created for
+// the same URL and username, as long as the password matches and its session is
+// still valid. This avoids paying the CAS authentication on each call in tools
+// constructing clients repeatedly. Options are only applied when a new client is
+// created.
+func NewCachedClient(url string, username string, password string, opts ...ClientOption) (*NetmagisClient, error) {
+	key := url + "\x00" + username
+	passwordHash := sha256.Sum256([]byte(password))
+
+	clientCacheMutex.Lock()
+	cached, found := clientCache[key]
+	if !found {
+		cached = &cachedClient{}
+		clientCache[key] = cached
+	}
+	clientCacheMutex.Unlock()
+
+	cached.mutex.Lock()
+	defer cached.mutex.Unlock()
+
+	if cached.client != nil && cached.passwordHash == passwordHash {
+		if loggedIn, err := cached.client.IsLoggedIn(); err == nil && loggedIn {
+			return cached.client, nil
+		}
+	}
+
+	client, err := NewClient(url, username, password, opts...)
+	if err != nil {
+		cached.client = nil
+		return nil, err
+	}
+	cached.client, cached.passwordHash = client, passwordHash
+	return client, nil
+}
+
+// Remove all the clients from the cache used by NewCachedClient.
+func ClearClientCache() {
+	clientCacheMutex.Lock()
+	defer clientCacheMutex.Unlock()
+	clientCache = map[string]*cachedClient{}
+}
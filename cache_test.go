@@ -0,0 +1,82 @@
+package netmagis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Start a server accepting any session (the start page does not redirect to CAS).
+// When `release` is not nil, the start page signals the request on `entered` and
+// waits for `release` to be closed.
+func newSessionServer(t *testing.T, entered chan struct{}, release chan struct{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" && release != nil {
+			entered <- struct{}{}
+			<-release
+		}
+		w.Write([]byte("<html><body></body></html>"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewCachedClientReuse(t *testing.T) {
+	ClearClientCache()
+	defer ClearClientCache()
+	server := newSessionServer(t, nil, nil)
+
+	first, err := NewCachedClient(server.URL, "user", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	second, err := NewCachedClient(server.URL, "user", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if first != second {
+		t.Error("expected the cached client to be reused")
+	}
+	other, err := NewCachedClient(server.URL, "user", "other secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if other == first {
+		t.Error("expected a new client for another password")
+	}
+}
+
+func TestNewCachedClientDoesNotBlockOtherKeys(t *testing.T) {
+	ClearClientCache()
+	defer ClearClientCache()
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	slowServer := newSessionServer(t, entered, release)
+	fastServer := newSessionServer(t, nil, nil)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := NewCachedClient(slowServer.URL, "user", "secret")
+		slowDone <- err
+	}()
+	<-entered
+
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := NewCachedClient(fastServer.URL, "user", "secret")
+		fastDone <- err
+	}()
+	select {
+	case err := <-fastDone:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("client creation blocked by the authentication on another URL")
+	}
+
+	close(release)
+	if err := <-slowDone; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -1,6 +1,7 @@
 package netmagis
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -11,14 +12,69 @@ var (
 		`<input type="hidden" name="execution" value="?([^"]*)"/>`,
 	)
 	loginErrorRegexp = regexp.MustCompile(
-		`<span>Authentication attempt has failed, likely due to invalid ',
-		'credentials. Please verify and try again. </span>`,
+		`<span>Authentication attempt has failed, likely due to invalid\s+` +
+			`credentials. Please verify and try again. </span>`,
 	)
 )
 
+// Error returned by CasClient.Login when CAS rejects the credentials.
+var ErrInvalidCredentials = &NetmagisError{msg: "invalid login or password"}
+
+// Errors returned by CasClient.Login when the credentials are valid but CAS refuses
+// the login because of the state of the account. They are kept in the chain of the
+// errors returned by NewClient, to be tested with errors.Is.
+var (
+	ErrAccountLocked      = &NetmagisError{msg: "CAS account locked"}
+	ErrAccountDisabled    = &NetmagisError{msg: "CAS account disabled"}
+	ErrPasswordExpired    = &NetmagisError{msg: "CAS password expired"}
+	ErrMustChangePassword = &NetmagisError{msg: "CAS password must be changed"}
+)
+
+// Error returned by CasClient when the Netmagis service is not registered in CAS
+// or not allowed for the user.
+var ErrServiceNotAuthorized = &NetmagisError{
+	msg: "service not authorized by CAS (check the CAS service registry or the user access rights)",
+}
+
+// Messages of the CAS views refusing the service (English and French versions of
+// the Apereo CAS views).
+var casServiceErrorRegexp = regexp.MustCompile(
+	`(?i)not authorized to use CAS|unauthorized service|service access denied|` +
+		`non autorisée à utiliser CAS|service non autorisé|accès au service refusé`,
+)
+
+// Messages of the CAS login views for the account states (English and French
+// versions of the Apereo CAS views).
+var casAccountStates = []struct {
+	pattern *regexp.Regexp
+	err     error
+}{
+	{regexp.MustCompile(`(?i)account (has been|is) locked|compte a été verrouillé`), ErrAccountLocked},
+	{regexp.MustCompile(`(?i)account (has been|is) disabled|compte a été désactivé`), ErrAccountDisabled},
+	{regexp.MustCompile(`(?i)password has expired|mot de passe a expiré`), ErrPasswordExpired},
+	{
+		regexp.MustCompile(`(?i)must change your password|devez changer votre mot de passe`),
+		ErrMustChangePassword,
+	},
+}
+
+// Error returned by NewClientFromTicket when the service does not accept the ticket.
+var ErrInvalidTicket = &NetmagisError{msg: "CAS ticket rejected by the service"}
+
 type CasClient struct {
 	LoginUrl   string
 	HttpClient *HttpClient
+	// Context bounding CAS requests (context.Background() when nil)
+	Context context.Context
+	// Maximum number of redirects followed after login (10 when zero)
+	MaxRedirects int
+}
+
+func (c *CasClient) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
 }
 
 //
@@ -28,16 +84,17 @@ func (c *CasClient) Connect(username string, password string) error {
 	loginPage, err := c.GetLoginPage()
 	if err != nil {
 		return &NetmagisError{
-			fmt.Sprintf(
+			msg: fmt.Sprintf(
 				"CAS login page error: %s", err.Error(),
 			),
+			err: err,
 		}
 	}
 
 	executionToken, err := c.FindExecutionToken(loginPage)
 	if err != nil {
 		return &NetmagisError{
-			fmt.Sprintf(
+			msg: fmt.Sprintf(
 				"CAS execution token error: %s", err.Error(),
 			),
 		}
@@ -46,9 +103,10 @@ func (c *CasClient) Connect(username string, password string) error {
 	err = c.Login(username, password, string(executionToken))
 	if err != nil {
 		return &NetmagisError{
-			fmt.Sprintf(
+			msg: fmt.Sprintf(
 				"CAS login error: %s", err.Error(),
 			),
+			err: err,
 		}
 	}
 
@@ -56,17 +114,20 @@ func (c *CasClient) Connect(username string, password string) error {
 }
 
 func (c *CasClient) GetLoginPage() ([]byte, error) {
-	res, err := c.HttpClient.Get(c.LoginUrl)
+	res, err := c.HttpClient.GetContext(c.context(), c.LoginUrl)
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
+	body, err := c.HttpClient.ReadBody(res)
+	if err == nil && casServiceErrorRegexp.Match(body) {
+		return nil, ErrServiceNotAuthorized
+	}
 	if res.StatusCode != 200 {
 		return nil, &NetmagisError{
-			fmt.Sprintf("HTTP Error: %s", res.Status),
+			msg: fmt.Sprintf("HTTP Error: %s", res.Status),
 		}
 	}
-
-	body, err := c.HttpClient.ReadBody(res)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +138,7 @@ func (c *CasClient) GetLoginPage() ([]byte, error) {
 func (c *CasClient) FindExecutionToken(loginPage []byte) ([]byte, error) {
 	submatch := executionRegexp.FindSubmatch(loginPage)
 	if len(submatch) == 0 {
-		return nil, &NetmagisError{"token not found"}
+		return nil, &NetmagisError{msg: "token not found"}
 	}
 	return submatch[1], nil
 }
@@ -90,27 +151,33 @@ func (c *CasClient) Login(username string, password string, executionToken strin
 		"execution": {executionToken},
 	}
 
-	res, err := c.HttpClient.PostForm(c.LoginUrl, formData)
-	defer res.Body.Close()
+	res, err := c.HttpClient.PostFormContext(c.context(), c.LoginUrl, formData)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	body, _ := c.HttpClient.ReadBody(res)
 	if loginErrorRegexp.Match(body) {
+		return ErrInvalidCredentials
+	}
+	if err := casAccountState(body); err != nil {
+		return err
+	}
+	if casServiceErrorRegexp.Match(body) {
+		return ErrServiceNotAuthorized
+	}
+
+	// Follow the service callback until landing on Netmagis
+	location := res.Header.Get("Location")
+	if location == "" {
 		return &NetmagisError{
-			fmt.Sprintf(
-				"invalid login or password",
-			),
+			msg: fmt.Sprintf("no service call back in CAS response (status: %s)", res.Status),
 		}
 	}
-
-	location := res.Header["Location"][0]
-	res, err = c.HttpClient.Get(location)
-	defer res.Body.Close()
-	if err != nil {
+	if err := c.followCallback(res.Request.URL, location); err != nil {
 		return &NetmagisError{
-			fmt.Sprintf(
+			msg: fmt.Sprintf(
 				"login call back error: %s", err.Error(),
 			),
 		}
@@ -118,3 +185,129 @@ func (c *CasClient) Login(username string, password string, executionToken strin
 
 	return nil
 }
+
+// Return the error of the account state reported by the CAS login page `body` (nil
+// when no state is reported).
+func casAccountState(body []byte) error {
+	for _, state := range casAccountStates {
+		if state.pattern.Match(body) {
+			return state.err
+		}
+	}
+	return nil
+}
+
+// Present `ticket` to the CAS service `serviceUrl`, which validates it against CAS
+// and opens a session, following the redirects until landing on the service.
+func (c *CasClient) ValidateTicket(serviceUrl string, ticket string) error {
+	ticketUrl, err := setQueryParam(serviceUrl, "ticket", ticket)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("invalid service URL: %s", err.Error())}
+	}
+	base, _ := url.Parse(ticketUrl)
+	return c.followCallback(base, ticketUrl)
+}
+
+// Return the service URL given in the `service` parameter of the CAS login URL.
+func casServiceUrl(loginUrl string) (string, error) {
+	parsedUrl, err := url.Parse(loginUrl)
+	if err != nil {
+		return "", err
+	}
+	service := parsedUrl.Query().Get("service")
+	if service == "" {
+		return "", fmt.Errorf("no service in CAS URL '%s'", loginUrl)
+	}
+	return service, nil
+}
+
+// Follow the redirect chain starting at `location` (relative to `base`) hop by hop,
+// until a non-redirect response. The chain is aborted when it exceeds MaxRedirects
+// or comes back to an already visited URL.
+func (c *CasClient) followCallback(base *url.URL, location string) error {
+	maxRedirects := c.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = maxFollowedRedirects
+	}
+
+	visited := map[string]bool{}
+	for hops := 0; ; hops++ {
+		next, err := base.Parse(location)
+		if err != nil {
+			return &NetmagisError{msg: fmt.Sprintf("invalid redirect location '%s'", location)}
+		}
+		if visited[next.String()] {
+			return &NetmagisError{msg: fmt.Sprintf("redirect loop on %s", next.Redacted())}
+		}
+		if hops >= maxRedirects {
+			return &NetmagisError{msg: fmt.Sprintf("stopped after %d redirects", maxRedirects)}
+		}
+		visited[next.String()] = true
+
+		res, err := c.HttpClient.GetContext(c.context(), next.String())
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		switch res.StatusCode {
+		case 301, 302, 303, 307, 308:
+			location = res.Header.Get("Location")
+			if location == "" {
+				return &NetmagisError{
+					msg: fmt.Sprintf("redirect without location from %s", next.Redacted()),
+				}
+			}
+			base = next
+		default:
+			return nil
+		}
+	}
+}
+
+// Check `username` and `password` against the CAS used by the Netmagis instance at
+// `url`, without keeping the session. Invalid credentials are reported with a false
+// result and a nil error, a non-nil error meaning the check could not be done
+// (connectivity problem, unexpected CAS answer, account state such as
+// ErrAccountLocked, ...).
+func VerifyCredentials(url string, username string, password string) (bool, error) {
+	httpClient, err := NewHttpClient()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := httpClient.GetRedirect(url + DefaultEndpoints.Start)
+	if err != nil {
+		return false, &NetmagisError{
+			msg: fmt.Sprintf("VerifyCredentials: unable to retrieve CAS URL: %s", err.Error()),
+		}
+	}
+	res.Body.Close()
+	cas := CasClient{LoginUrl: res.Header.Get("Location"), HttpClient: httpClient}
+
+	loginPage, err := cas.GetLoginPage()
+	if err != nil {
+		return false, &NetmagisError{
+			msg: fmt.Sprintf("VerifyCredentials: CAS login page error: %s", err.Error()),
+			err: err,
+		}
+	}
+	executionToken, err := cas.FindExecutionToken(loginPage)
+	if err != nil {
+		return false, &NetmagisError{
+			msg: fmt.Sprintf("VerifyCredentials: CAS execution token error: %s", err.Error()),
+		}
+	}
+
+	err = cas.Login(username, password, string(executionToken))
+	if err == ErrInvalidCredentials {
+		return false, nil
+	}
+	if err != nil {
+		return false, &NetmagisError{
+			msg: fmt.Sprintf("VerifyCredentials: CAS login error: %s", err.Error()),
+			err: err,
+		}
+	}
+	return true, nil
+}
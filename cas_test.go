@@ -0,0 +1,61 @@
+package netmagis
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCasAccountState(t *testing.T) {
+	tests := map[string]error{
+		"cas_locked.html":      ErrAccountLocked,
+		"cas_locked_fr.html":   ErrAccountLocked,
+		"cas_disabled.html":    ErrAccountDisabled,
+		"cas_expired.html":     ErrPasswordExpired,
+		"cas_must_change.html": ErrMustChangePassword,
+		"cas_login.html":       nil,
+	}
+	for name, expected := range tests {
+		if err := casAccountState([]byte(fixture(t, name))); err != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, err)
+		}
+	}
+}
+
+// Return a CAS client whose login page is `loginPage` and whose login answers with
+// `loginResult`.
+func newTestCasClient(t *testing.T, loginPage string, loginResult string) *CasClient {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cas/login" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Write([]byte(fixture(t, loginResult)))
+			return
+		}
+		w.Write([]byte(fixture(t, loginPage)))
+	})
+	return &CasClient{LoginUrl: client.BaseUrl + "/cas/login", HttpClient: client.HttpClient}
+}
+
+func TestCasConnectAccountState(t *testing.T) {
+	cas := newTestCasClient(t, "cas_login.html", "cas_expired.html")
+	if err := cas.Connect("jdoe", "secret"); !errors.Is(err, ErrPasswordExpired) {
+		t.Fatalf("expected ErrPasswordExpired, got %v", err)
+	}
+}
+
+func TestCasServiceNotAuthorized(t *testing.T) {
+	// Refused when requesting the login page...
+	cas := newTestCasClient(t, "cas_service_unauthorized.html", "cas_login.html")
+	if err := cas.Connect("jdoe", "secret"); !errors.Is(err, ErrServiceNotAuthorized) {
+		t.Errorf("login page: expected ErrServiceNotAuthorized, got %v", err)
+	}
+
+	// ... or after the login, for services restricted to some users
+	cas = newTestCasClient(t, "cas_login.html", "cas_service_unauthorized.html")
+	if err := cas.Connect("jdoe", "secret"); !errors.Is(err, ErrServiceNotAuthorized) {
+		t.Errorf("login: expected ErrServiceNotAuthorized, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package netmagis
+
+import (
+	"time"
+)
+
+// Source of time used by time-dependent features (timestamps, polling, backoff,
+// rate limiting), replaceable with WithClock for testing them without real sleeps.
+type Clock interface {
+	Now() time.Time
+	// Same as time.After
+	After(d time.Duration) <-chan time.Time
+}
+
+// Clock based on the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Clock of the client (the real clock unless set with WithClock).
+func (c *NetmagisClient) clockOrDefault() Clock {
+	if c.clock == nil {
+		return realClock{}
+	}
+	return c.clock
+}
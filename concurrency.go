@@ -0,0 +1,48 @@
+package netmagis
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Run `operation` for each index in [0, count), as concurrently as the client
+// allows (see WithMaxConcurrency), and return the error of each run. The
+// concurrency slots are shared by all the batch operations of the client and of
+// its copies. With a single slot, operations run sequentially in order.
+func (c *NetmagisClient) forEach(count int, operation func(idx int) error) []error {
+	errs := make([]error, count)
+	semaphore := c.semaphore
+	if semaphore == nil || cap(semaphore) == 1 {
+		for idx := 0; idx < count; idx++ {
+			errs[idx] = c.withSlot(semaphore, func() error { return operation(idx) })
+		}
+		return errs
+	}
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < count; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs[idx] = c.withSlot(semaphore, func() error { return operation(idx) })
+		}(idx)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Run `operation` once a slot of `semaphore` (if any) is available.
+func (c *NetmagisClient) withSlot(semaphore chan struct{}, operation func() error) error {
+	if semaphore == nil {
+		return operation()
+	}
+	select {
+	case semaphore <- struct{}{}:
+	case <-c.context().Done():
+		return &NetmagisError{
+			msg: fmt.Sprintf("waiting for a concurrency slot interrupted: %s", c.context().Err()),
+		}
+	}
+	defer func() { <-semaphore }()
+	return operation()
+}
@@ -0,0 +1,63 @@
+package netmagis
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Return a handler of the host modification serving the /mod form `form` with the
+// cookie `cookie`, and recording the form loads and the submissions.
+func csrfHandler(t *testing.T, form string, cookie string, loads *[]*http.Request, submitted *[]*http.Request) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("action") != "store" {
+			*loads = append(*loads, r)
+			http.SetCookie(w, &http.Cookie{Name: cookie, Value: "s3cr3t", Path: "/"})
+			w.Write([]byte(fixture(t, form)))
+			return
+		}
+		*submitted = append(*submitted, r)
+		w.Write([]byte(fixture(t, "mod_stored.html")))
+	}
+}
+
+func TestCSRFCookie(t *testing.T) {
+	loads, submitted := []*http.Request{}, []*http.Request{}
+	client := newTestClient(t, csrfHandler(t, "mod_host_csrf.html", "csrf_token", &loads, &submitted))
+
+	if err := client.SetComment("www.example.com", "new comment"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 {
+		t.Fatalf("expected 1 submission, got %d", len(submitted))
+	}
+	if value := submitted[0].PostForm.Get("csrf_token"); value != "s3cr3t" {
+		t.Errorf("expected the cookie echoed in the csrf_token field, got %q", value)
+	}
+	if value := submitted[0].Header.Get("X-CSRF-Token"); value != "s3cr3t" {
+		t.Errorf("expected the cookie echoed in the X-CSRF-Token header, got %q", value)
+	}
+	for _, r := range loads {
+		if value := r.Header.Get("X-CSRF-Token"); value != "" {
+			t.Errorf("unexpected CSRF header on the form load: %q", value)
+		}
+	}
+}
+
+func TestCSRFCookieUndeclaredField(t *testing.T) {
+	loads, submitted := []*http.Request{}, []*http.Request{}
+	client := newTestClient(t, csrfHandler(t, "mod_host.html", "xsrf_cookie", &loads, &submitted))
+
+	if err := client.SetComment("www.example.com", "new comment"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 {
+		t.Fatalf("expected 1 submission, got %d", len(submitted))
+	}
+	if _, found := submitted[0].PostForm["xsrf_cookie"]; found {
+		t.Error("cookie echoed in a field the form does not declare")
+	}
+	if value := submitted[0].Header.Get("X-XSRF-TOKEN"); value != "s3cr3t" {
+		t.Errorf("expected the cookie echoed in the X-XSRF-TOKEN header, got %q", value)
+	}
+}
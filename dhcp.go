@@ -0,0 +1,247 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DHCP profile, as defined by Netmagis administrators.
+type DHCPProfile struct {
+	Id          int
+	Name        string
+	Description string
+	// DHCP options (ISC dhcpd syntax) included in the configuration of the hosts
+	// using the profile.
+	Options string
+}
+
+// List the DHCP profiles the user can assign to hosts (from the `iddhcpprof` select
+// of the /add form), as a map from profile name to id.
+func (c *NetmagisClient) ListDHCPProfiles() (map[string]int, error) {
+	body, err := c.Call(c.endpoints.Add, url.Values{}, func(body string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse /add HTML response: %s", err.Error()),
+		}
+	}
+
+	profiles := map[string]int{}
+	for _, node := range htmlquery.Find(doc, "//select[@name='iddhcpprof']/option") {
+		id, err := strconv.Atoi(htmlquery.SelectAttr(node, "value"))
+		// The "No profile" option has id 0
+		if err != nil || id == 0 {
+			continue
+		}
+		profiles[nodeText(node)] = id
+	}
+	return profiles, nil
+}
+
+// Retrieve the details of a DHCP profile, by name or id, from the DHCP profiles
+// administration page.
+func (c *NetmagisClient) GetDHCPProfile(profile string) (*DHCPProfile, error) {
+	profiles, err := c.ListDHCPProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DHCPProfile{}
+	for name, id := range profiles {
+		if name == profile || strconv.Itoa(id) == profile {
+			result.Id = id
+			result.Name = name
+		}
+	}
+	if result.Id == 0 {
+		return nil, &NetmagisError{msg: fmt.Sprintf("unknown DHCP profile '%s'", profile)}
+	}
+
+	body, err := c.Call(
+		c.endpoints.AdmRef,
+		url.Values{"type": {"dhcpprof"}},
+		func(body string) bool { return true },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse /admref HTML response: %s", err.Error()),
+		}
+	}
+
+	for _, table := range htmlquery.Find(doc, "//table") {
+		for _, row := range parseTable(table) {
+			if row["name"] != result.Name {
+				continue
+			}
+			result.Description = row["comment"]
+			result.Options = row["dhcp_text"]
+			if result.Options == "" {
+				result.Options = row["text"]
+			}
+			return result, nil
+		}
+	}
+	return nil, &NetmagisError{
+		msg: fmt.Sprintf("DHCP profile '%s' not found in profiles administration page", profile),
+	}
+}
+
+// Return the id and name of the DHCP profile `profile` (name or id).
+func (c *NetmagisClient) dhcpProfile(profile string) (int, string, error) {
+	profiles, err := c.ListDHCPProfiles()
+	if err != nil {
+		return 0, "", err
+	}
+	for name, id := range profiles {
+		if name == profile || strconv.Itoa(id) == profile {
+			return id, name, nil
+		}
+	}
+	return 0, "", &NetmagisError{msg: fmt.Sprintf("unknown DHCP profile '%s'", profile)}
+}
+
+// Set the DHCP profile (by name or id) of the host `fqdn`, or disable DHCP for the
+// host when `profile` is empty ("No profile"). The change is checked with a
+// follow-up read of the host.
+func (c *NetmagisClient) SetDHCPProfile(fqdn string, profile string) error {
+	id := 0
+	if profile != "" {
+		var err error
+		if id, _, err = c.dhcpProfile(profile); err != nil {
+			return err
+		}
+
+		host, err := c.GetHost(fqdn)
+		if err != nil {
+			return err
+		}
+		if host != nil && host["mac"] == "" {
+			return &NetmagisError{
+				msg: fmt.Sprintf("host '%s' has no MAC address, required for DHCP", fqdn),
+			}
+		}
+	}
+
+	if err := c.updateHostFields(fqdn, map[string]interface{}{"iddhcpprof": id}); err != nil {
+		return err
+	}
+
+	host, err := c.GetHost(fqdn)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("verification read failed: %s", err.Error())}
+	}
+	if host == nil || normalizeHostValue(host["iddhcpprof"]) != strconv.Itoa(id) {
+		return &NetmagisError{
+			msg: fmt.Sprintf(
+				"DHCP profile of '%s' is '%v' after update (expected '%d')",
+				fqdn, host["iddhcpprof"], id,
+			),
+			kind: ErrVerificationFailed,
+		}
+	}
+	return nil
+}
+
+// Static DHCP reservation: a host declared with a MAC address.
+type DHCPReservation struct {
+	Name    string
+	IP      string
+	MAC     string
+	Network string
+}
+
+// List the DHCP reservations of a network (given by its CIDR) or of a DHCP profile
+// (given by name or id), from the hosts declared with a MAC address. Listing by
+// profile crawls all the networks the user is allowed to consult and, when the
+// listings do not display the profile, reads each host with a MAC address, so it
+// is slow on large instances. A network whose listing has no MAC address column
+// is reported as not having DHCP enabled.
+func (c *NetmagisClient) ListDHCPReservations(profileOrNetwork string) ([]DHCPReservation, error) {
+	if _, _, err := net.ParseCIDR(profileOrNetwork); err == nil {
+		hosts, err := c.ListHosts(profileOrNetwork)
+		if err != nil {
+			return nil, err
+		}
+		if len(hosts) > 0 && !hasHostField(hosts, "mac", "mac_address") {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf("DHCP is not enabled on network %s (no MAC address displayed)", profileOrNetwork),
+			}
+		}
+		return dhcpReservations(hosts, func(Host) (bool, error) { return true, nil })
+	}
+
+	id, name, err := c.dhcpProfile(profileOrNetwork)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := c.ListHosts()
+	if err != nil {
+		return nil, err
+	}
+	return dhcpReservations(hosts, func(host Host) (bool, error) {
+		if profile, found := host["dhcp_profile"].(string); found {
+			return profile == name, nil
+		}
+		form, err := c.GetHost(host["name"].(string))
+		if err != nil || form == nil {
+			return false, err
+		}
+		return normalizeHostValue(form["iddhcpprof"]) == strconv.Itoa(id), nil
+	})
+}
+
+// Build the reservations of the hosts with a MAC address selected by `selected`.
+func dhcpReservations(hosts []Host, selected func(Host) (bool, error)) ([]DHCPReservation, error) {
+	reservations := []DHCPReservation{}
+	for _, host := range hosts {
+		mac := ""
+		for _, field := range []string{"mac", "mac_address"} {
+			if value, ok := host[field].(string); ok && value != "" {
+				mac = value
+			}
+		}
+		if mac == "" {
+			continue
+		}
+		ok, err := selected(host)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		network, _ := host["network"].(string)
+		reservations = append(reservations, DHCPReservation{
+			Name:    host["name"].(string),
+			IP:      hostAddress(host),
+			MAC:     mac,
+			Network: network,
+		})
+	}
+	return reservations, nil
+}
+
+// Report whether one of the `hosts` has one of the `fields`.
+func hasHostField(hosts []Host, fields ...string) bool {
+	for _, host := range hosts {
+		for _, field := range fields {
+			if _, found := host[field]; found {
+				return true
+			}
+		}
+	}
+	return false
+}
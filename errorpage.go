@@ -0,0 +1,65 @@
+package netmagis
+
+import (
+	"github.com/antchfx/htmlquery"
+	"regexp"
+	"strings"
+)
+
+var errorCodeRegexp = regexp.MustCompile(`(?i)\berror\s*(?:code|#)\s*:?\s*([A-Za-z0-9_-]+)`)
+
+// Content of a Netmagis error page (see NetmagisError.ErrorPage).
+type ErrorPage struct {
+	// Error message (in red)
+	Message string
+	// Error code, when given
+	Code string
+	// Other messages of the page (e.g. suggested action)
+	Details []string
+	// Values of the submitted form echoed back by the page
+	Values map[string]string
+}
+
+// Parse a Netmagis error page (containing "<h2>Error!</h2>"). Missing parts are
+// left empty.
+func parseErrorPage(body string) *ErrorPage {
+	page := &ErrorPage{Values: map[string]string{}}
+	if submatch := errorRegexp.FindStringSubmatch(body); submatch != nil {
+		page.Message = cleanText(tagRegexp.ReplaceAllString(submatch[1], ""))
+	}
+	if submatch := errorCodeRegexp.FindStringSubmatch(body); submatch != nil {
+		page.Code = submatch[1]
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return page
+	}
+	for _, node := range htmlquery.Find(doc, "//blockquote | //p") {
+		text := nodeText(node)
+		if text == "" || text == page.Message || text == "Error!" {
+			continue
+		}
+		if page.Message == "" {
+			page.Message = text
+			continue
+		}
+		page.Details = append(page.Details, text)
+	}
+	for _, node := range htmlquery.Find(doc, "//form//input | //form//textarea") {
+		name := htmlquery.SelectAttr(node, "name")
+		if name == "" || formTokenRegexp.MatchString(name) {
+			continue
+		}
+		value := htmlquery.SelectAttr(node, "value")
+		if node.Data == "textarea" {
+			value = htmlquery.InnerText(node)
+		}
+		page.Values[name] = value
+	}
+	if page.Message == "" {
+		page.Message = "unknown error"
+	}
+	page.Message = strings.Trim(page.Message, `"`)
+	return page
+}
@@ -1,9 +1,121 @@
 package netmagis
 
+import (
+	"errors"
+	"regexp"
+)
+
+// Kinds of errors, to be tested with errors.Is.
+var (
+	// The HTTP request failed (connection error, timeout, ...).
+	ErrTransport = errors.New("HTTP transport error")
+	// Netmagis answered without the expected success marker.
+	ErrValidation = errors.New("unexpected Netmagis output")
+	// The state read after a write contradicts the expected outcome.
+	ErrVerificationFailed = errors.New("verification after write failed")
+	// A Netmagis quota or limit (maximum number of hosts, records, ...) was hit.
+	// Retrying will fail the same way.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// The user has no rights on the object (e.g. a host in a domain or network not
+	// managed by the user's group).
+	ErrPermissionDenied = errors.New("permission denied")
+	// The object does not exist.
+	ErrNotFound = errors.New("not found")
+	// Several objects match and none was selected.
+	ErrAmbiguous = errors.New("ambiguous")
+	// The operation would break a DNS rule (see WithStrictDNS).
+	ErrDNSRule = errors.New("DNS rule violation")
+)
+
+// Patterns of the Netmagis error messages reported as ErrQuotaExceeded. They can be
+// replaced (e.g. for a localized instance) with WithQuotaPatterns.
+var DefaultQuotaPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)quota`),
+	regexp.MustCompile(`(?i)(maximum|max\.?) (number of )?(hosts|records|names|addresses)`),
+	regexp.MustCompile(`(?i)limit (reached|exceeded)`),
+}
+
+// Patterns of the Netmagis error messages reported as ErrPermissionDenied. They can
+// be replaced with WithPermissionPatterns.
+var DefaultPermissionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)permission denied|access denied`),
+	regexp.MustCompile(`(?i)(you (don't|do not) have|no) (rights?|access|permission)`),
+	regexp.MustCompile(`(?i)(not|isn't|is not) (allowed|authorized) (for|in|on|to)`),
+}
+
+// Patterns of the Netmagis messages reporting an unknown name: the error message
+// of the forms (reported as ErrNotFound, e.g. GetHost returning nil) and the
+// answer of the search page (Search returning nil). English and French messages
+// are recognized; the French ones are not checked against a localized
+// installation, so they can be replaced with WithNotFoundPatterns if they differ.
+var DefaultNotFoundPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`Name '[^']*' does not exist`),
+	regexp.MustCompile(`String '[^']*' not found`),
+	regexp.MustCompile(`(?i)nom '[^']*' n'existe pas`),
+	regexp.MustCompile(`(?i)cha[iî]ne '[^']*' (non trouv[ée]e|introuvable)`),
+}
+
 type NetmagisError struct {
-	msg string
+	msg  string
+	kind error
+	// Parsed error page, for errors reported by Netmagis
+	page *ErrorPage
+	// Operation which failed (see Op)
+	op string
+	// Underlying error (e.g. the HTTP client error)
+	err error
+}
+
+// Return the NetmagisError in the chain of `err`, for accessing its components.
+func AsNetmagisError(err error) (*NetmagisError, bool) {
+	var netmagisErr *NetmagisError
+	ok := errors.As(err, &netmagisErr)
+	return netmagisErr, ok
 }
 
 func (error *NetmagisError) Error() string {
 	return error.msg
 }
+
+// Report whether the error is of the kind `target` (e.g. ErrValidation).
+func (error *NetmagisError) Is(target error) bool {
+	return error.kind != nil && error.kind == target
+}
+
+// Return the error page displayed by Netmagis, or nil when the error was not
+// reported by Netmagis (HTTP error, validation error, ...).
+func (error *NetmagisError) ErrorPage() *ErrorPage {
+	return error.page
+}
+
+// Return the kind of the error (ErrTransport, ErrValidation, ...), or nil for a
+// generic error.
+func (error *NetmagisError) Kind() error {
+	return error.kind
+}
+
+// Return the message displayed by Netmagis, or an empty string when the error was
+// not reported by Netmagis.
+func (error *NetmagisError) ServerMessage() string {
+	if error.page == nil {
+		return ""
+	}
+	return error.page.Message
+}
+
+// Return the operation which failed, with the same labels as the metrics (e.g.
+// `add/add-host` or `search`, see Metrics), or an empty string when the error was
+// raised before any request.
+func (error *NetmagisError) Op() string {
+	if error.op == "" {
+		if wrapped, ok := error.err.(*NetmagisError); ok {
+			return wrapped.Op()
+		}
+	}
+	return error.op
+}
+
+// Return the underlying error, if any.
+func (error *NetmagisError) Unwrap() error {
+	return error.err
+}
@@ -0,0 +1,68 @@
+package netmagis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorKind(t *testing.T) {
+	client := newTestClient(t, nil)
+	tests := []struct {
+		message string
+		kind    error
+	}{
+		{"Name 'www.example.com' does not exist", ErrNotFound},
+		{"String 'www' not found", ErrNotFound},
+		{"You don't have rights on domain 'other.org'", ErrPermissionDenied},
+		{"Permission denied", ErrPermissionDenied},
+		{"Address 192.0.2.1 is not allowed for your group", ErrPermissionDenied},
+		{"Maximum number of hosts reached", ErrQuotaExceeded},
+		{"Invalid MAC address '00:11'", nil},
+	}
+	for _, test := range tests {
+		if kind := client.errorKind(test.message); kind != test.kind {
+			t.Errorf("%q: expected %v, got %v", test.message, test.kind, kind)
+		}
+	}
+}
+
+func TestGetHostNotFoundOrDenied(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{"/mod": "mod_notfound.html"}))
+	host, err := client.GetHost("new.example.com")
+	if host != nil || err != nil {
+		t.Errorf("not found: expected nil host and error, got %v, %v", host, err)
+	}
+
+	client = newTestClient(t, fixtureHandler(t, map[string]string{"/mod": "mod_denied.html"}))
+	host, err = client.GetHost("www.other.org")
+	if host != nil || !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("denied: expected a permission error, got %v, %v", host, err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("denied: reported as not found: %v", err)
+	}
+}
+
+func TestNotFoundLocalized(t *testing.T) {
+	tests := []struct {
+		search, mod string
+	}{
+		{"search_notfound.html", "mod_notfound.html"},
+		{"search_notfound_fr.html", "mod_notfound_fr.html"},
+	}
+	for _, test := range tests {
+		client := newTestClient(t, fixtureHandler(t, map[string]string{
+			"/search": test.search,
+			"/mod":    test.mod,
+		}))
+
+		host, err := client.Search("nowhere.example.com")
+		if host != nil || err != nil {
+			t.Errorf("%s: expected nil host and error, got %v, %v", test.search, host, err)
+		}
+		host, err = client.GetHost("nowhere.example.com")
+		if host != nil || err != nil {
+			t.Errorf("%s: expected nil host and error, got %v, %v", test.mod, host, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+)
+
+// Maximum number of intermediate forms followed by an add operation.
+const maxFormSteps = 5
+
+// Submit an add or delete form, following the intermediate forms Netmagis may
+// answer with before the success page: when the response does not pass
+// `checkFunc` but contains a form continuing the operation, this form is submitted
+// with its current values, up to maxFormSteps times.
+//
+// Unless the client forces the steps (see withForceSteps), only a confirmation of
+// an addition restating the submitted values, without warnings, is followed; other
+// intermediate forms fail with an error giving the page text.
+func (c *NetmagisClient) callSteps(uri string, formData url.Values, checkFunc func(body string) bool) (string, error) {
+	var nextForm url.Values
+	confirmation := false
+	stepFunc := func(body string) bool {
+		if checkFunc(body) {
+			return true
+		}
+		nextForm, confirmation = nextStepForm(body, uri, formData)
+		return nextForm != nil
+	}
+
+	for step := 0; ; step++ {
+		nextForm = nil
+		body, err := c.call(uri, formData, stepFunc)
+		if err != nil || nextForm == nil {
+			return body, err
+		}
+		if !c.forceSteps {
+			if warnings := parseWarnings(body); len(warnings) > 0 {
+				return "", &NetmagisError{
+					msg: fmt.Sprintf(
+						"%s: confirmation required (use `force` to override): %s",
+						uri, strings.Join(warnings, "; "),
+					),
+					kind: ErrValidation,
+				}
+			}
+			if !confirmation || uri != c.endpoints.Add {
+				return "", &NetmagisError{
+					msg: fmt.Sprintf(
+						"%s: unexpected intermediate form (use `force` to submit it): %q",
+						uri, bodySnippet(body),
+					),
+					kind: ErrValidation,
+				}
+			}
+		}
+		if step >= maxFormSteps {
+			return "", &NetmagisError{
+				msg:  fmt.Sprintf("%s: still no result after %d intermediate forms", uri, maxFormSteps),
+				kind: ErrValidation,
+			}
+		}
+		formData = nextForm
+	}
+}
+
+// Return a shallow copy of the client submitting all the intermediate forms of the
+// add and delete operations when `force` is set (see callSteps).
+func (c *NetmagisClient) withForceSteps(force bool) *NetmagisClient {
+	if !force {
+		return c
+	}
+	clone := *c
+	clone.forceSteps = true
+	return &clone
+}
+
+// Return the fields of the form of `body` continuing the operation submitted to
+// `uri` with `formData`, or nil when there is none. Such a form posts to the same
+// endpoint, has an `action` and carries the submitted name as hidden field. It must
+// also move the operation on, with an `action` other than the submitted one or a
+// `confirm` field which was not submitted: the submitted form re-rendered (e.g.
+// after a validation failure) is not a step.
+//
+// The form is also reported as a confirmation when it only restates the submission:
+// the submitted fields it carries have their submitted value (except `action`,
+// `confirm` and anti-CSRF tokens) and its other fields are hidden.
+func nextStepForm(body string, uri string, formData url.Values) (url.Values, bool) {
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+
+	endpoint := strings.Trim(uri, "/")
+	for _, form := range htmlquery.Find(doc, "//form") {
+		action := htmlquery.SelectAttr(form, "action")
+		if action != "" && !strings.HasSuffix(strings.Split(action, "?")[0], endpoint) {
+			continue
+		}
+		fields := formFields(form)
+		if fields.Get("action") == "" || fields.Get("name") != formData.Get("name") {
+			continue
+		}
+		_, confirmSubmitted := formData["confirm"]
+		_, confirmAsked := fields["confirm"]
+		if fields.Get("action") == formData.Get("action") && (confirmSubmitted || !confirmAsked) {
+			continue
+		}
+		// Anti-CSRF tokens may not be repeated in the intermediate form
+		for name, values := range formData {
+			if _, found := fields[name]; !found && formTokenRegexp.MatchString(name) {
+				fields[name] = values
+			}
+		}
+		return fields, isConfirmationForm(form, formData)
+	}
+	return nil, false
+}
+
+// Report whether the intermediate form `form` only restates the submitted
+// `formData` (see nextStepForm).
+func isConfirmationForm(form *html.Node, formData url.Values) bool {
+	for _, node := range htmlquery.Find(form, "//input | //select | //textarea") {
+		name := htmlquery.SelectAttr(node, "name")
+		if name == "" || name == "action" || name == "confirm" || formTokenRegexp.MatchString(name) {
+			continue
+		}
+		if _, submitted := formData[name]; !submitted {
+			fieldType := strings.ToLower(htmlquery.SelectAttr(node, "type"))
+			if node.Data != "input" || (fieldType != "hidden" && fieldType != "submit") {
+				return false
+			}
+		}
+	}
+	fields := formFields(form)
+	for name, values := range formData {
+		if name == "action" || name == "confirm" || formTokenRegexp.MatchString(name) {
+			continue
+		}
+		if stepValues, found := fields[name]; found && strings.Join(stepValues, "\n") != strings.Join(values, "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the values a browser would submit for `form` (hidden and text inputs,
+// checked boxes, selected options and the first named submit button).
+func formFields(form *html.Node) url.Values {
+	fields := url.Values{}
+	submitted := false
+	for _, node := range htmlquery.Find(form, "//input") {
+		name := htmlquery.SelectAttr(node, "name")
+		if name == "" {
+			continue
+		}
+		value := htmlquery.SelectAttr(node, "value")
+		switch strings.ToLower(htmlquery.SelectAttr(node, "type")) {
+		case "checkbox", "radio":
+			if hasAttr(node, "checked") {
+				fields.Add(name, value)
+			}
+		case "submit", "image":
+			if !submitted {
+				fields.Set(name, value)
+				submitted = true
+			}
+		case "button", "reset", "file":
+		default:
+			fields.Add(name, value)
+		}
+	}
+	for _, node := range htmlquery.Find(form, "//select") {
+		name := htmlquery.SelectAttr(node, "name")
+		options := htmlquery.Find(node, "//option")
+		if name == "" || len(options) == 0 {
+			continue
+		}
+		selected := options[0]
+		for _, option := range options {
+			if hasAttr(option, "selected") {
+				selected = option
+				break
+			}
+		}
+		fields.Set(name, htmlquery.SelectAttr(selected, "value"))
+	}
+	for _, node := range htmlquery.Find(form, "//textarea") {
+		if name := htmlquery.SelectAttr(node, "name"); name != "" {
+			fields.Set(name, htmlquery.InnerText(node))
+		}
+	}
+	return fields
+}
+
+// Report whether `node` has the attribute `key` (e.g. `checked`), whatever its value.
+func hasAttr(node *html.Node, key string) bool {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package netmagis
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Return a handler of the host addition, answering the submissions of /add by
+// action with the fixtures of `steps` and recording them in `submitted`.
+func addFlowHandler(t *testing.T, steps map[string]string, submitted *[]url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/mod":
+			w.Write([]byte(fixture(t, "mod_notfound.html")))
+		case r.URL.Path == "/add" && r.PostForm.Get("action") == "":
+			w.Write([]byte(fixture(t, "add_form.html")))
+		case r.URL.Path == "/add":
+			*submitted = append(*submitted, r.PostForm)
+			name, found := steps[r.PostForm.Get("action")]
+			if !found {
+				t.Errorf("unexpected action %q", r.PostForm.Get("action"))
+				name = "add_rerender.html"
+			}
+			w.Write([]byte(fixture(t, name)))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestAddHostTwoPages(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, addFlowHandler(t, map[string]string{
+		"add-host":         "add_step.html",
+		"add-host-confirm": "add_success.html",
+	}, &submitted))
+
+	if err := client.AddHost("new.example.com", "192.0.2.30", map[string]interface{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("expected 2 submissions, got %d: %v", len(submitted), submitted)
+	}
+	if action := submitted[1].Get("action"); action != "add-host-confirm" {
+		t.Errorf("expected the intermediate form to be submitted, got action %q", action)
+	}
+	if addr := submitted[1].Get("addr"); addr != "192.0.2.30" {
+		t.Errorf("expected the intermediate form values, got addr %q", addr)
+	}
+}
+
+func TestAddHostRerenderedForm(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, addFlowHandler(t, map[string]string{
+		"add-host": "add_rerender.html",
+	}, &submitted))
+
+	err := client.AddHost("new.example.com", "192.0.2.30", map[string]interface{}{"mac": "00:11"})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if len(submitted) != 1 {
+		t.Errorf("expected the re-rendered form not to be submitted, got %d submissions", len(submitted))
+	}
+}
+
+func TestAddHostUnrecognizedSteps(t *testing.T) {
+	for _, name := range []string{"add_step_warning.html", "add_step_choice.html"} {
+		submitted := []url.Values{}
+		client := newTestClient(t, addFlowHandler(t, map[string]string{"add-host": name}, &submitted))
+
+		err := client.AddHost("new.example.com", "192.0.2.30", map[string]interface{}{})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+		if len(submitted) != 1 {
+			t.Errorf("%s: expected the intermediate form not to be submitted, got %d submissions",
+				name, len(submitted))
+		}
+	}
+}
+
+func TestAddHostForce(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, addFlowHandler(t, map[string]string{
+		"add-host":         "add_step_warning.html",
+		"add-host-confirm": "add_success.html",
+	}, &submitted))
+
+	params := map[string]interface{}{"mac": "00:11:22:33:44:55", "force": true}
+	if err := client.AddHost("new.example.com", "192.0.2.30", params); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("expected 2 submissions, got %d", len(submitted))
+	}
+	for idx, values := range submitted {
+		if _, found := values["force"]; found {
+			t.Errorf("submission %d: unexpected force field", idx)
+		}
+	}
+}
+
+// Return a handler of the host removal, answering the first submission with the
+// confirmation form and the next ones with the success page.
+func delFlowHandler(t *testing.T, submitted *[]url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/del" || len(r.PostForm) == 0 {
+			w.Write([]byte("<html><body></body></html>"))
+			return
+		}
+		*submitted = append(*submitted, r.PostForm)
+		if len(*submitted) == 1 {
+			w.Write([]byte(fixture(t, "del_confirm.html")))
+			return
+		}
+		w.Write([]byte(fixture(t, "del_success.html")))
+	}
+}
+
+func TestDelHostConfirmation(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, delFlowHandler(t, &submitted))
+
+	err := client.DelHost("www.example.com")
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "removed with it") {
+		t.Fatalf("expected a validation error giving the page text, got %v", err)
+	}
+	if len(submitted) != 1 {
+		t.Errorf("expected the confirmation not to be submitted, got %d submissions", len(submitted))
+	}
+
+	submitted = []url.Values{}
+	if err := client.DelHostParams("www.example.com", map[string]interface{}{"force": true}); err != nil {
+		t.Fatalf("unexpected error with force: %s", err)
+	}
+	if len(submitted) != 2 || submitted[1].Get("confirm") != "yes" {
+		t.Errorf("expected the confirmation to be submitted with force, got %v", submitted)
+	}
+	if _, found := submitted[0]["force"]; found {
+		t.Error("unexpected force field")
+	}
+}
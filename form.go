@@ -0,0 +1,131 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+)
+
+// Form served by Netmagis, for introspecting the fields an instance expects (see
+// FetchModForm).
+type FormSchema struct {
+	// Endpoint serving the form
+	Uri string
+	// Raw HTML page
+	Raw string
+	// Fields, in the order of the page
+	Fields []FormField
+}
+
+// Field of a form.
+type FormField struct {
+	Name string
+	// Input type (`text`, `hidden`, `checkbox`, ...), `select` or `textarea`
+	Type  string
+	Value string
+	// Checked state of checkboxes and radio buttons
+	Checked bool
+	// Options of selects
+	Options []FormOption
+}
+
+// Option of a select field.
+type FormOption struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// Return the form to modify the host `fqdn` (the /mod form parsed by GetHost), with
+// all its fields, their current values and the options of the selects, e.g. for
+// checking the fields expected by an instance before building the params of
+// UpdateHost.
+func (c *NetmagisClient) FetchModForm(fqdn string) (*FormSchema, error) {
+	fqdn = normalizeFqdn(fqdn)
+	name, domain := c.splitFqdn(fqdn)
+	body, err := c.Call(
+		c.endpoints.Mod,
+		url.Values{"action": {"edit"}, "name": {name}, "domain": {domain}},
+		func(body string) bool { return true },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse %s HTML response: %s", c.endpoints.Mod, err.Error()),
+		}
+	}
+	return &FormSchema{Uri: c.endpoints.Mod, Raw: body, Fields: parseFormFields(doc)}, nil
+}
+
+// Return the named fields of the forms of `node`.
+func parseFormFields(node *html.Node) []FormField {
+	fields := []FormField{}
+	for _, field := range htmlquery.Find(node, "//*[self::input or self::select or self::textarea]") {
+		name := htmlquery.SelectAttr(field, "name")
+		if name == "" {
+			continue
+		}
+		formField := FormField{Name: name, Type: field.Data}
+		switch field.Data {
+		case "input":
+			formField.Type = strings.ToLower(htmlquery.SelectAttr(field, "type"))
+			if formField.Type == "" {
+				formField.Type = "text"
+			}
+			formField.Value = cleanText(htmlquery.SelectAttr(field, "value"))
+			formField.Checked = hasAttr(field, "checked")
+		case "select":
+			for _, option := range htmlquery.Find(field, "//option") {
+				formOption := FormOption{
+					Value:    cleanText(htmlquery.SelectAttr(option, "value")),
+					Label:    nodeText(option),
+					Selected: hasAttr(option, "selected"),
+				}
+				if formOption.Selected {
+					formField.Value = formOption.Value
+				}
+				formField.Options = append(formField.Options, formOption)
+			}
+		case "textarea":
+			formField.Value = cleanText(htmlquery.InnerText(field))
+		}
+		fields = append(fields, formField)
+	}
+	return fields
+}
+
+// Return the field `name`, or nil when the form has no such field.
+func (schema *FormSchema) Field(name string) *FormField {
+	for idx := range schema.Fields {
+		if schema.Fields[idx].Name == name {
+			return &schema.Fields[idx]
+		}
+	}
+	return nil
+}
+
+// Return the current values of the fields, as a params map for AddHost/UpdateHost
+// (checkboxes as booleans, buttons ignored).
+func (schema *FormSchema) Params() map[string]interface{} {
+	params := map[string]interface{}{}
+	for _, field := range schema.Fields {
+		switch field.Type {
+		case "submit", "reset", "button", "image":
+		case "checkbox":
+			params[field.Name] = field.Checked
+		case "radio":
+			if field.Checked {
+				params[field.Name] = field.Value
+			}
+		default:
+			params[field.Name] = field.Value
+		}
+	}
+	return params
+}
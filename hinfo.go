@@ -0,0 +1,67 @@
+package netmagis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Characters rejected by Netmagis in HINFO hardware and OS values.
+const invalidHinfoChars = "/\"\\ \t\r\n"
+
+// Host information (HINFO record): hardware and operating system. It can be given
+// as `hinfo` parameter instead of the raw "HW/OS" string.
+type Hinfo struct {
+	Hardware string
+	OS       string
+}
+
+// Return the "HW/OS" form of the HINFO, as used by Netmagis.
+func (hinfo Hinfo) String() string {
+	return hinfo.Hardware + "/" + hinfo.OS
+}
+
+// Check that the HINFO parts are not empty and contain no character rejected by
+// Netmagis.
+func (hinfo Hinfo) Validate() error {
+	for _, part := range [][2]string{{"hardware", hinfo.Hardware}, {"OS", hinfo.OS}} {
+		part, value := part[0], part[1]
+		if value == "" {
+			return &NetmagisError{msg: fmt.Sprintf("HINFO %s is empty", part)}
+		}
+		if strings.ContainsAny(value, invalidHinfoChars) {
+			return &NetmagisError{
+				msg: fmt.Sprintf("invalid character in HINFO %s '%s'", part, value),
+			}
+		}
+	}
+	return nil
+}
+
+// Parse a "HW/OS" HINFO value.
+func ParseHinfo(value string) (Hinfo, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Hinfo{}, &NetmagisError{
+			msg: fmt.Sprintf("invalid HINFO '%s' (expected HW/OS)", value),
+		}
+	}
+	hinfo := Hinfo{Hardware: strings.TrimSpace(parts[0]), OS: strings.TrimSpace(parts[1])}
+	return hinfo, hinfo.Validate()
+}
+
+// Return the form value of the `hinfo` parameter, given either as raw string or as
+// Hinfo.
+func hinfoParam(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case Hinfo:
+		return v.String(), v.Validate()
+	case *Hinfo:
+		if v == nil {
+			return "", &NetmagisError{msg: "invalid nil *Hinfo for hinfo"}
+		}
+		return v.String(), v.Validate()
+	}
+	return "", &NetmagisError{msg: fmt.Sprintf("invalid type %T for hinfo", value)}
+}
@@ -0,0 +1,29 @@
+package netmagis
+
+import "testing"
+
+func TestHinfoParam(t *testing.T) {
+	var nilHinfo *Hinfo
+	tests := []struct {
+		value    interface{}
+		expected string
+		valid    bool
+	}{
+		{"PC/Unix", "PC/Unix", true},
+		{Hinfo{Hardware: "PC", OS: "Windows"}, "PC/Windows", true},
+		{&Hinfo{Hardware: "PC", OS: "Windows"}, "PC/Windows", true},
+		{nilHinfo, "", false},
+		{42, "", false},
+	}
+	for _, test := range tests {
+		value, err := hinfoParam(test.value)
+		switch {
+		case test.valid && err != nil:
+			t.Errorf("%#v: unexpected error: %s", test.value, err)
+		case !test.valid && err == nil:
+			t.Errorf("%#v: expected an error, got %q", test.value, value)
+		case value != test.expected:
+			t.Errorf("%#v: expected %q, got %q", test.value, test.expected, value)
+		}
+	}
+}
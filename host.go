@@ -0,0 +1,255 @@
+package netmagis
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields computed by the library, ignored when comparing hosts.
+var computedHostFields = map[string]bool{
+	"is_alias":    true,
+	"record_type": true,
+	"naddr":       true,
+	"hinfo_pair":  true,
+	"view":        true,
+	"created":     true,
+	"modified":    true,
+	"modified_by": true,
+}
+
+// Date formats used by Netmagis (depending on version and locale).
+var netmagisDateFormats = []string{
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"2006/01/02",
+	"2006-01-02",
+}
+
+var dateAuthorRegexp = regexp.MustCompile(`^(.*?)\s*\(([^)]*)\)\s*$`)
+
+// Parse a Netmagis date, optionally followed by the author between parens (e.g.
+// `2020/01/02 10:00:00 (jdoe)`). The zero time is returned for unparsable dates.
+func parseNetmagisDate(value string) (time.Time, string) {
+	author := ""
+	if submatch := dateAuthorRegexp.FindStringSubmatch(value); submatch != nil {
+		value, author = submatch[1], submatch[2]
+	}
+	for _, format := range netmagisDateFormats {
+		if date, err := time.ParseInLocation(format, strings.TrimSpace(value), time.Local); err == nil {
+			return date, author
+		}
+	}
+	return time.Time{}, author
+}
+
+// Difference on a field between two host states.
+type FieldDiff struct {
+	Field   string
+	Current interface{}
+	Desired interface{}
+}
+
+// Normalize a host value to its form representation so values of different types
+// (e.g. TTL as int or string) can be compared.
+func normalizeHostValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case int:
+		return intToStr(v)
+	case bool:
+		return boolToStr(v)
+	case []string:
+		return strings.Join(v, " ")
+	case string:
+		if v == "-1" {
+			return ""
+		}
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// Return the fields of `desired` whose value differs in `current`, sorted by field
+// name. Only the fields set in `desired` are compared and computed fields (like
+// `is_alias`) are ignored.
+func HostDiff(current Host, desired Host) []FieldDiff {
+	diffs := []FieldDiff{}
+	for field, value := range desired {
+		if computedHostFields[field] {
+			continue
+		}
+		if normalizeHostValue(current[field]) != normalizeHostValue(value) {
+			diffs = append(diffs, FieldDiff{Field: field, Current: current[field], Desired: value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs
+}
+
+func (diff FieldDiff) String() string {
+	return fmt.Sprintf(
+		"%s: %s -> %s",
+		diff.Field,
+		strconv.Quote(normalizeHostValue(diff.Current)),
+		strconv.Quote(normalizeHostValue(diff.Desired)),
+	)
+}
+
+// Return the idrr (Netmagis resource record id) of the record of type `recordType`
+// (A, AAAA, CNAME or MX) of `fqdn`. When `value` is not empty, the record must also
+// have this value (an address for A/AAAA, the target for CNAME and MX).
+//
+// In Netmagis, the idrr identifies a name: all the addresses of a round-robin name
+// share the same idrr, whereas an alias has its own idrr.
+func (c *NetmagisClient) ResolveIDRR(fqdn string, recordType string, value string) (int, error) {
+	host, err := c.Search(fqdn)
+	if err != nil {
+		return 0, err
+	}
+	if host == nil {
+		return 0, &NetmagisError{msg: fmt.Sprintf("no record found for '%s'", fqdn)}
+	}
+
+	var values []string
+	switch strings.ToUpper(recordType) {
+	case "A", "AAAA":
+		if host["is_alias"].(bool) {
+			return 0, &NetmagisError{msg: fmt.Sprintf("'%s' is an alias", fqdn)}
+		}
+		for _, address := range searchValues(host, "ip_addresses", "ip_address") {
+			isIPv4 := net.ParseIP(address).To4() != nil
+			if isIPv4 == (strings.ToUpper(recordType) == "A") {
+				values = append(values, address)
+			}
+		}
+	case "CNAME":
+		if !host["is_alias"].(bool) {
+			return 0, &NetmagisError{msg: fmt.Sprintf("'%s' is not an alias", fqdn)}
+		}
+		values = []string{host["name"].(string)}
+	case "MX":
+		values = searchValues(host, "mx", "mail_relays")
+	default:
+		return 0, &NetmagisError{msg: fmt.Sprintf("unsupported record type '%s'", recordType)}
+	}
+
+	if len(values) == 0 {
+		return 0, &NetmagisError{msg: fmt.Sprintf("no %s record found for '%s'", recordType, fqdn)}
+	}
+	if value != "" {
+		found := false
+		for _, v := range values {
+			found = found || v == value
+		}
+		if !found {
+			return 0, &NetmagisError{
+				msg: fmt.Sprintf(
+					"no %s record with value '%s' found for '%s' (found: %s)",
+					recordType, value, fqdn, strings.Join(values, ", "),
+				),
+			}
+		}
+	}
+
+	form, err := c.GetHost(fqdn)
+	if err != nil {
+		return 0, err
+	}
+	if form == nil {
+		return 0, &NetmagisError{msg: fmt.Sprintf("unable to retrieve idrr of '%s'", fqdn)}
+	}
+	return hostIdrr(form)
+}
+
+// Return the idrr of the host `host` returned by GetHost. An error is returned
+// when its form does not give it (e.g. a page without `idrr` field).
+func hostIdrr(host Host) (int, error) {
+	idrr, ok := host["idrr"].(int)
+	if !ok {
+		return 0, &NetmagisError{
+			msg:  fmt.Sprintf("no idrr found in the form of host '%v'", host["name"]),
+			kind: ErrValidation,
+		}
+	}
+	return idrr, nil
+}
+
+// Parameters accepted by the host methods (AddHost, UpdateHost, DelHostParams).
+var knownParams = map[string]bool{
+	"ttl":           true,
+	"mac":           true,
+	"iddhcpprof":    true,
+	"hinfo":         true,
+	"comment":       true,
+	"respname":      true,
+	"respmail":      true,
+	"sendsmtp":      true,
+	"multiple":      true,
+	"naddr":         true,
+	"confirm":       true,
+	"check_aliases": true,
+	"safe":          true,
+	"force":         true,
+	"allow_special": true,
+	"idview":        true,
+}
+
+// Build a params map from a struct whose fields are tagged with the parameter
+// names, e.g.:
+//
+//	type HostParams struct {
+//		TTL     int    `netmagis:"ttl"`
+//		Comment string `netmagis:"comment,omitempty"`
+//	}
+//
+// Untagged fields (or tagged with "-") are ignored and `omitempty` skips zero
+// values. An error is returned for unknown parameter names.
+func ParamsFromStruct(v interface{}) (map[string]interface{}, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, &NetmagisError{msg: fmt.Sprintf("expected a struct, got %s", value.Kind())}
+	}
+
+	params := map[string]interface{}{}
+	for idx := 0; idx < value.NumField(); idx++ {
+		tag := value.Type().Field(idx).Tag.Get("netmagis")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if !knownParams[name] {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf(
+					"unknown parameter '%s' (field %s)", name, value.Type().Field(idx).Name,
+				),
+			}
+		}
+
+		field := value.Field(idx)
+		if !field.CanInterface() {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf("field %s is not exported", value.Type().Field(idx).Name),
+			}
+		}
+		if len(parts) > 1 && parts[1] == "omitempty" && field.IsZero() {
+			continue
+		}
+		params[name] = field.Interface()
+	}
+	return params, nil
+}
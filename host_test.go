@@ -0,0 +1,229 @@
+package netmagis
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolveIDRR(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{
+		"/search": "search_host.html",
+		"/mod":    "mod_host.html",
+	}))
+	idrr, err := client.ResolveIDRR("www.example.com", "A", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if idrr != 1234 {
+		t.Errorf("expected idrr 1234, got %d", idrr)
+	}
+}
+
+func TestResolveIDRRWithoutIdrr(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{
+		"/search": "search_host.html",
+		"/mod":    "mod_noidrr.html",
+	}))
+	_, err := client.ResolveIDRR("www.example.com", "A", "192.0.2.1")
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestParamsFromStruct(t *testing.T) {
+	type hostParams struct {
+		TTL      int    `netmagis:"ttl"`
+		Comment  string `netmagis:"comment,omitempty"`
+		Mail     string `netmagis:"respmail,omitempty"`
+		SendSMTP bool   `netmagis:"sendsmtp"`
+		Internal string
+		Skipped  string `netmagis:"-"`
+	}
+
+	params, err := ParamsFromStruct(&hostParams{
+		TTL: 3600, Comment: "web server", SendSMTP: true, Internal: "x", Skipped: "y",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]interface{}{"ttl": 3600, "comment": "web server", "sendsmtp": true}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+}
+
+func TestParamsFromStructErrors(t *testing.T) {
+	type unknownParam struct {
+		Comment string `netmagis:"coment"`
+	}
+	type unexportedParam struct {
+		comment string `netmagis:"comment"`
+	}
+
+	tests := map[string]interface{}{
+		"unknown parameter":     unknownParam{Comment: "typo"},
+		"unexported field":      unexportedParam{comment: "hidden"},
+		"not a struct":          map[string]interface{}{"ttl": 3600},
+		"pointer to non-struct": new(int),
+	}
+	for name, value := range tests {
+		if _, err := ParamsFromStruct(value); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseNetmagisDate(t *testing.T) {
+	tests := []struct {
+		value  string
+		date   time.Time
+		author string
+	}{
+		{"2021/03/04 10:20:30 (jdoe)", time.Date(2021, 3, 4, 10, 20, 30, 0, time.Local), "jdoe"},
+		{"2021-03-04 10:20", time.Date(2021, 3, 4, 10, 20, 0, 0, time.Local), ""},
+		{"04/03/2021 10:20:30", time.Date(2021, 3, 4, 10, 20, 30, 0, time.Local), ""},
+		{"2021/03/04", time.Date(2021, 3, 4, 0, 0, 0, 0, time.Local), ""},
+		{"yesterday (jdoe)", time.Time{}, "jdoe"},
+		{"", time.Time{}, ""},
+	}
+	for _, test := range tests {
+		date, author := parseNetmagisDate(test.value)
+		if !date.Equal(test.date) || author != test.author {
+			t.Errorf("%q: expected %s (%q), got %s (%q)", test.value, test.date, test.author, date, author)
+		}
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{
+		"/search": "search_host.html",
+		"/mod":    "mod_host.html",
+	}))
+	host, err := client.Search("www.example.com")
+	if err != nil {
+		t.Fatalf("Search: unexpected error: %s", err)
+	}
+	form, err := client.GetHost("www.example.com")
+	if err != nil {
+		t.Fatalf("GetHost: unexpected error: %s", err)
+	}
+
+	created := time.Date(2019, 5, 6, 8, 0, 0, 0, time.Local)
+	modified := time.Date(2021, 3, 4, 10, 20, 30, 0, time.Local)
+	for method, result := range map[string]Host{"Search": host, "GetHost": form} {
+		if date, _ := result["created"].(time.Time); !date.Equal(created) {
+			t.Errorf("%s: expected creation %s, got %v", method, created, result["created"])
+		}
+		if date, _ := result["modified"].(time.Time); !date.Equal(modified) {
+			t.Errorf("%s: expected modification %s, got %v", method, modified, result["modified"])
+		}
+		if result["modified_by"] != "jdoe" {
+			t.Errorf("%s: expected modification by jdoe, got %v", method, result["modified_by"])
+		}
+	}
+}
+
+func TestTimestampsNotDisplayed(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{"/mod": "mod_noidrr.html"}))
+	form, err := client.GetHost("www.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, field := range []string{"created", "modified"} {
+		if date, ok := form[field].(time.Time); !ok || !date.IsZero() {
+			t.Errorf("%s: expected zero time, got %v", field, form[field])
+		}
+	}
+}
+
+func TestSendSMTPParsing(t *testing.T) {
+	tests := []struct {
+		search, mod string
+		expected    bool
+	}{
+		{"search_host.html", "mod_host.html", true},
+		{"search_host_nosmtp.html", "mod_host_nosmtp.html", false},
+	}
+	for _, test := range tests {
+		client := newTestClient(t, fixtureHandler(t, map[string]string{
+			"/search": test.search,
+			"/mod":    test.mod,
+		}))
+		searched, err := client.Search("www.example.com")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.search, err)
+		}
+		host, err := client.GetHost("www.example.com")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.mod, err)
+		}
+		if searched["sendsmtp"] != test.expected || host["sendsmtp"] != test.expected {
+			t.Errorf("%s/%s: expected sendsmtp %t, got %v (Search) and %v (GetHost)",
+				test.search, test.mod, test.expected, searched["sendsmtp"], host["sendsmtp"])
+		}
+		if searched.SendSMTP() != host.SendSMTP() {
+			t.Errorf("%s/%s: SendSMTP differs between Search and GetHost", test.search, test.mod)
+		}
+	}
+}
+
+// Return a handler of the host modification, serving the /mod form `form` and
+// recording the submissions.
+func modHandler(t *testing.T, form string, submitted *[]url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path != "/mod":
+			http.NotFound(w, r)
+		case r.PostForm.Get("action") == "store":
+			*submitted = append(*submitted, r.PostForm)
+			w.Write([]byte(fixture(t, "mod_stored.html")))
+		default:
+			w.Write([]byte(fixture(t, form)))
+		}
+	}
+}
+
+func TestSetCommentPreservesFields(t *testing.T) {
+	for _, form := range []string{"mod_host.html", "mod_host_attrs.html"} {
+		submitted := []url.Values{}
+		client := newTestClient(t, modHandler(t, form, &submitted))
+		if err := client.SetComment("www.example.com", "new comment"); err != nil {
+			t.Fatalf("%s: unexpected error: %s", form, err)
+		}
+		if len(submitted) != 1 {
+			t.Fatalf("%s: expected 1 submission, got %d", form, len(submitted))
+		}
+		expected := map[string]string{
+			"idrr":       "1234",
+			"idview":     "2",
+			"iddhcpprof": "3",
+			"ttl":        "3600",
+			"mac":        "00:11:22:33:44:55",
+			"hinfo":      "PC/Unix",
+			"respmail":   "jerome@example.com",
+			"sendsmtp":   "1",
+			"comment":    "new comment",
+		}
+		for field, value := range expected {
+			if submitted[0].Get(field) != value {
+				t.Errorf("%s: %s: expected %q, got %q", form, field, value, submitted[0].Get(field))
+			}
+		}
+	}
+}
+
+func TestSetCommentUnselectedOption(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, modHandler(t, "mod_host_unselected.html", &submitted))
+	if err := client.SetComment("www.example.com", "new comment"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if len(submitted) != 0 {
+		t.Errorf("unexpected submissions: %v", submitted)
+	}
+}
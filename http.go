@@ -1,18 +1,65 @@
 package netmagis
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 )
 
+const (
+	maxFollowedRedirects = 10
+	// Maximum length of the body excerpts included in errors
+	maxBodySnippet = 200
+)
+
+var (
+	sensitiveHeaderRegexp = regexp.MustCompile(`(?i)(auth|cookie|token|secret|key|password)`)
+	htmlTagRegexp         = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]*>`)
+	csrfCookieRegexp      = regexp.MustCompile(`(?i)(csrf|xsrf)`)
+)
+
 type HttpClient struct {
 	HttpClient *http.Client
+	// Static headers added to every request
+	Headers http.Header
+	// Number of retries of GetRedirect on HTTP and server (5xx) errors, waiting
+	// RetryBackoff (doubled after each attempt) between attempts
+	Retries      int
+	RetryBackoff time.Duration
+	// Source of time for the backoff (the real clock when nil)
+	Clock Clock
 }
 
+// Return a copy of `headers` in which the values of sensitive headers
+// (authorization, cookies, tokens, ...) are redacted, for logging purposes.
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := http.Header{}
+	for name, values := range headers {
+		if sensitiveHeaderRegexp.MatchString(name) {
+			redacted[name] = []string{"REDACTED"}
+		} else {
+			redacted[name] = values
+		}
+	}
+	return redacted
+}
+
+// Context key marking requests for which redirects must be followed.
+type followRedirectsKey struct{}
+
 //
 // Initialize HTTP client
 //
@@ -30,7 +77,7 @@ func NewHttpClient() (*HttpClient, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf(
+			msg: fmt.Sprintf(
 				"unable to initialize cookiejar: %s", err.Error(),
 			),
 		}
@@ -51,9 +98,15 @@ func NewHttpClient() (*HttpClient, error) {
 	httpClient := &HttpClient{
 		HttpClient: &http.Client{
 			Timeout: time.Duration(60) * time.Second,
-			// Disable redirects
+			// Disable redirects, unless explicitly requested (see GetFollow)
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
+				if follow, _ := req.Context().Value(followRedirectsKey{}).(bool); !follow {
+					return http.ErrUseLastResponse
+				}
+				if len(via) >= maxFollowedRedirects {
+					return errors.New("stopped after 10 redirects")
+				}
+				return nil
 			},
 			Jar: jar,
 		},
@@ -61,52 +114,251 @@ func NewHttpClient() (*HttpClient, error) {
 	return httpClient, nil
 }
 
-func (c *HttpClient) Get(url string) (*http.Response, error) {
-	res, err := c.HttpClient.Get(url)
+// Transport using distinct TLS configurations for the Netmagis host and for the
+// other hosts (i.e. CAS), which may be signed by different authorities.
+type splitTLSTransport struct {
+	netmagisHost      string
+	netmagisTransport http.RoundTripper
+	otherTransport    http.RoundTripper
+}
+
+func newSplitTLSTransport(netmagisHost string, netmagisTLS *tls.Config, otherTLS *tls.Config) *splitTLSTransport {
+	newTransport := func(config *tls.Config) http.RoundTripper {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		return transport
+	}
+	return &splitTLSTransport{
+		netmagisHost:      netmagisHost,
+		netmagisTransport: newTransport(netmagisTLS),
+		otherTransport:    newTransport(otherTLS),
+	}
+}
+
+func (t *splitTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.netmagisHost {
+		return t.netmagisTransport.RoundTrip(req)
+	}
+	return t.otherTransport.RoundTrip(req)
+}
+
+// Send a request built from the given parameters, bound to `ctx`.
+func (c *HttpClient) do(ctx context.Context, method string, url string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf(
+			msg: fmt.Sprintf("invalid request: %s", err.Error()),
+		}
+	}
+	for name, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf(
 				"HTTP error: %s", err.Error(),
 			),
 		}
 	}
+	if res == nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("HTTP error: empty response from %s", url)}
+	}
 	return res, nil
 }
 
+// Return the double-submit CSRF cookies (cookies whose name contains `csrf` or
+// `xsrf`) set for `u`, whose values must be echoed back on mutating requests. They
+// are automatically sent as header with the Netmagis mutating requests, and in the
+// form fields named after them.
+func (c *HttpClient) CSRFCookies(u *url.URL) []*http.Cookie {
+	cookies := []*http.Cookie{}
+	if c.HttpClient.Jar == nil {
+		return cookies
+	}
+	for _, cookie := range c.HttpClient.Jar.Cookies(u) {
+		if csrfCookieRegexp.MatchString(cookie.Name) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
+
+// Return the header conventionally echoing the CSRF cookie `name`.
+func csrfHeader(name string) string {
+	if strings.Contains(strings.ToLower(name), "xsrf") {
+		return "X-XSRF-TOKEN"
+	}
+	return "X-CSRF-Token"
+}
+
+func (c *HttpClient) Get(url string) (*http.Response, error) {
+	return c.GetContext(context.Background(), url)
+}
+
+func (c *HttpClient) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, url, nil, "")
+}
+
+// Same as Get but redirects are followed and the final response is returned.
+func (c *HttpClient) GetFollow(url string) (*http.Response, error) {
+	return c.GetFollowContext(context.Background(), url)
+}
+
+func (c *HttpClient) GetFollowContext(ctx context.Context, url string) (*http.Response, error) {
+	ctx = context.WithValue(ctx, followRedirectsKey{}, true)
+	return c.do(ctx, http.MethodGet, url, nil, "")
+}
+
 func (c *HttpClient) GetRedirect(url string) (*http.Response, error) {
-	res, err := c.HttpClient.Get(url)
+	return c.GetRedirectContext(context.Background(), url)
+}
+
+func (c *HttpClient) GetRedirectContext(ctx context.Context, url string) (*http.Response, error) {
+	res, err := c.getRetry(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
 	if res.StatusCode != 301 && res.StatusCode != 302 {
-		return nil, &NetmagisError{
-			fmt.Sprintf(
-				"invalid status code: '%d' (30{1,2} expected)", res.StatusCode,
-			),
-		}
+		return nil, unexpectedStatusError(res, "30{1,2} expected")
 	}
 
 	return res, nil
 }
 
+// Same as GetContext, but HTTP errors and server errors (5xx) are retried
+// according to Retries and RetryBackoff. The response of the last attempt is
+// returned.
+func (c *HttpClient) getRetry(ctx context.Context, url string) (*http.Response, error) {
+	backoff := c.RetryBackoff
+	for retry := 0; ; retry++ {
+		res, err := c.GetContext(ctx, url)
+		if retry >= c.Retries || (err == nil && res.StatusCode < 500) {
+			return res, err
+		}
+		if err == nil {
+			res.Body.Close()
+			err = fmt.Errorf("server error: %s", res.Status)
+		}
+
+		select {
+		case <-c.clock().After(backoff):
+		case <-ctx.Done():
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf("%s (retries interrupted: %s)", err.Error(), ctx.Err()),
+			}
+		}
+		backoff *= 2
+	}
+}
+
+// Clock of the HTTP client (the real clock unless Clock is set).
+func (c *HttpClient) clock() Clock {
+	if c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
+
+// Build an error for the unexpected response `res`, giving the status, the type of
+// page returned and an excerpt of its content for diagnosing. The body is
+// consumed and closed.
+func unexpectedStatusError(res *http.Response, expected string) error {
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+	return &NetmagisError{
+		msg: fmt.Sprintf(
+			"invalid status code: '%d' (%s), got %s: %q",
+			res.StatusCode, expected, pageType(res, string(body)), bodySnippet(string(body)),
+		),
+	}
+}
+
+// Guess the type of page of a response, for diagnostics.
+func pageType(res *http.Response, body string) string {
+	switch {
+	case res.StatusCode >= 500:
+		return "server error page"
+	case executionRegexp.MatchString(body) || strings.Contains(body, `name="password"`):
+		return "login page"
+	case strings.Contains(body, "<h2>Error!</h2>"):
+		return "Netmagis error page"
+	case res.StatusCode == 401 || res.StatusCode == 403:
+		return "access denied page"
+	case strings.TrimSpace(body) == "":
+		return "empty page"
+	}
+	return "unexpected page"
+}
+
+// Return the text of `body` (without markup and with spaces collapsed), truncated
+// to maxBodySnippet characters.
+func bodySnippet(body string) string {
+	text := strings.Join(strings.Fields(htmlTagRegexp.ReplaceAllString(body, " ")), " ")
+	if runes := []rune(text); len(runes) > maxBodySnippet {
+		return string(runes[:maxBodySnippet]) + "..."
+	}
+	return text
+}
+
+// Read the body of `res`. Bodies compressed with gzip or deflate (which the
+// transport only decompresses when it requested compression itself) are
+// decompressed. Other encodings (e.g. `br`) can't be parsed, so they fail with an
+// error instead of returning the encoded bytes.
 func (c *HttpClient) ReadBody(res *http.Response) ([]byte, error) {
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("body read error: %s", err.Error()),
+			msg: fmt.Sprintf("body read error: %s", err.Error()),
 		}
 	}
+	if res.Uncompressed || len(body) == 0 {
+		return body, nil
+	}
+
+	var reader io.ReadCloser
+	switch encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))); encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// Deflate is normally zlib-wrapped, but some servers send raw deflate
+		if reader, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	case "", "identity":
+		return body, nil
+	default:
+		return nil, &NetmagisError{msg: fmt.Sprintf("unsupported content encoding '%s'", encoding)}
+	}
+	if err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("body decompression error: %s", err.Error())}
+	}
+	defer reader.Close()
+
+	body, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("body decompression error: %s", err.Error())}
+	}
 	return body, nil
 }
 
 func (c *HttpClient) PostForm(url string, formData url.Values) (*http.Response, error) {
-	res, err := c.HttpClient.PostForm(url, formData)
-	if err != nil {
-		return nil, &NetmagisError{
-			fmt.Sprintf("HTTP error: %s", err.Error()),
-		}
-	}
+	return c.PostFormContext(context.Background(), url, formData)
+}
 
-	return res, nil
+func (c *HttpClient) PostFormContext(ctx context.Context, url string, formData url.Values) (*http.Response, error) {
+	return c.do(
+		ctx,
+		http.MethodPost,
+		url,
+		strings.NewReader(formData.Encode()),
+		"application/x-www-form-urlencoded",
+	)
 }
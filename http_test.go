@@ -0,0 +1,124 @@
+package netmagis
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Clock firing timers immediately and recording the requested delays.
+type fakeClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestGetRetryClock(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{}
+	client, err := NewHttpClient()
+	if err != nil {
+		t.Fatalf("unable to initialize client: %s", err)
+	}
+	client.Retries, client.RetryBackoff, client.Clock = 2, time.Hour, clock
+
+	res, err := client.GetRedirectContext(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	res.Body.Close()
+	if expected := []time.Duration{time.Hour, 2 * time.Hour}; !reflect.DeepEqual(clock.delays, expected) {
+		t.Errorf("expected backoffs %v, got %v", expected, clock.delays)
+	}
+}
+
+// Return `content` compressed with `encoding` ("deflate" for zlib-wrapped deflate,
+// "raw-deflate" for raw deflate).
+func compress(t *testing.T, encoding string, content string) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	var writer interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch encoding {
+	case "deflate":
+		writer = zlib.NewWriter(buffer)
+	case "raw-deflate":
+		writer, _ = flate.NewWriter(buffer, flate.DefaultCompression)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	writer.Write([]byte(content))
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func TestReadBodyCompressed(t *testing.T) {
+	content := fixture(t, "search_host.html")
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"gzip", "gzip", []byte(fixture(t, "search_host.html.gz"))},
+		{"zlib deflate", "deflate", compress(t, "deflate", content)},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", content)},
+		{"identity", "", []byte(content)},
+	}
+	for _, test := range tests {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if test.encoding != "" {
+				w.Header().Set("Content-Encoding", test.encoding)
+			}
+			w.Write(test.body)
+		})
+
+		host, err := client.Search("www.example.com")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if host == nil || host["name"] != "www.example.com" {
+			t.Errorf("%s: expected www.example.com, got %v", test.name, host)
+		}
+	}
+}
+
+func TestReadBodyUnsupportedEncoding(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte{0x1b, 0x03, 0x00})
+	})
+	_, err := client.Search("www.example.com")
+	if err == nil || !strings.Contains(err.Error(), "unsupported content encoding 'br'") {
+		t.Errorf("expected an unsupported encoding error, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package netmagis
+
+import (
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// Maximum length of a confirmation message: longer texts are paragraphs (help,
+// ...) merely mentioning the marker.
+const maxConfirmationLength = 256
+
+// Messages displayed by Netmagis when an operation succeeds.
+type Markers struct {
+	HostAdded   string
+	HostUpdated string
+	HostRemoved string
+	AliasAdded  string
+	MXAdded     string
+	SRVAdded    string
+}
+
+// Markers of a standard (english) Netmagis installation.
+var DefaultMarkers = Markers{
+	HostAdded:   "Host has been added.",
+	HostUpdated: "The modification has been stored in database",
+	HostRemoved: "has been removed",
+	AliasAdded:  "The alias has been added",
+	MXAdded:     "The MX has been added",
+	SRVAdded:    "The SRV record has been added",
+}
+
+// Return a check function accepting a response when `marker` is the message of a
+// confirmation element, i.e. a short standalone text outside links, lists and form
+// controls. This avoids false positives on pages merely mentioning the marker
+// (e.g. in a list of recently removed items or a help text).
+func confirmationCheck(marker string) func(body string) bool {
+	return func(body string) bool {
+		if !strings.Contains(body, marker) {
+			return false
+		}
+		doc, err := htmlquery.Parse(strings.NewReader(body))
+		if err != nil {
+			return false
+		}
+		for _, node := range htmlquery.Find(doc, "//text()") {
+			if strings.Contains(node.Data, marker) && isConfirmationText(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isConfirmationText(node *html.Node) bool {
+	if len(cleanText(node.Data)) > maxConfirmationLength {
+		return false
+	}
+	for node = node.Parent; node != nil; node = node.Parent {
+		switch node.Data {
+		case "a", "li", "option", "textarea", "script", "style", "title":
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package netmagis
+
+import "testing"
+
+func TestConfirmationCheck(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		marker   string
+		expected bool
+	}{
+		{"del_success.html", DefaultMarkers.HostRemoved, true},
+		{"add_success.html", DefaultMarkers.HostAdded, true},
+		{"mod_stored.html", DefaultMarkers.HostUpdated, true},
+		// Marker in a list of recently removed items
+		{"del_recent_list.html", DefaultMarkers.HostRemoved, false},
+		// Marker in a help paragraph, select options and a link
+		{"del_help.html", DefaultMarkers.HostRemoved, false},
+		// Page of another operation
+		{"add_success.html", DefaultMarkers.HostRemoved, false},
+	}
+	for _, test := range tests {
+		if ok := confirmationCheck(test.marker)(fixture(t, test.fixture)); ok != test.expected {
+			t.Errorf("%s (%q): expected %t, got %t", test.fixture, test.marker, test.expected, ok)
+		}
+	}
+}
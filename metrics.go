@@ -0,0 +1,69 @@
+package netmagis
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Receiver of the client metrics, for wiring a monitoring system (Prometheus, ...)
+// without the library depending on it. Each request to Netmagis is reported with
+// an operation label made of the endpoint and the form action (e.g. `add/add-host`
+// or `search`). See WithMetrics.
+type Metrics interface {
+	CallStarted(operation string)
+	// `errorKind` is empty on success, see ErrorKindLabel.
+	CallFinished(operation string, duration time.Duration, errorKind string)
+}
+
+// Metrics discarding everything, used unless WithMetrics is given.
+type noopMetrics struct{}
+
+func (noopMetrics) CallStarted(operation string) {}
+
+func (noopMetrics) CallFinished(operation string, duration time.Duration, errorKind string) {}
+
+// Metrics of the client (no-op unless set with WithMetrics).
+func (c *NetmagisClient) metricsOrDefault() Metrics {
+	if c.metrics == nil {
+		return noopMetrics{}
+	}
+	return c.metrics
+}
+
+// Return the operation label of a request.
+func operationName(uri string, formData url.Values) string {
+	operation := strings.Trim(uri, "/")
+	if action := formData.Get("action"); action != "" {
+		operation += "/" + action
+	}
+	return operation
+}
+
+// Return a label for the kind of `err`, suitable for metrics: empty for a nil
+// error, `transport`, `validation`, `verification`, `quota`, `permission`,
+// `not_found`, `ambiguous`, `dns_rule` or `other`.
+func ErrorKindLabel(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrTransport):
+		return "transport"
+	case errors.Is(err, ErrValidation):
+		return "validation"
+	case errors.Is(err, ErrVerificationFailed):
+		return "verification"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota"
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrAmbiguous):
+		return "ambiguous"
+	case errors.Is(err, ErrDNSRule):
+		return "dns_rule"
+	}
+	return "other"
+}
@@ -1,23 +1,39 @@
 package netmagis
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/antchfx/htmlquery"
 	"golang.org/x/net/html"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/mail"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
 	fqdnRegexp           = regexp.MustCompile(`^[0-9a-zA-Z-]{2,63}(\.[a-zA-Z-]{2,63})+\.[a-zA-Z]{2,63}$`)
 	errorRegexp          = regexp.MustCompile(`<blockquote><FONT COLOR="#FF0000">(.*)</FONT></blockquote>`)
-	hostNotFoundRegexp   = regexp.MustCompile(`String '[^']*' not found`)
 	searchRegexpValidate = regexp.MustCompile(`is a.* in view `)
+	valueSeparatorRegexp = regexp.MustCompile(`[\s,;]+`)
+	ipAddressRegexp      = regexp.MustCompile(`\b(?:\d{1,3}(?:\.\d{1,3}){3}|[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7})\b`)
+	searchRecordRegexp   = regexp.MustCompile(`is an? ([^<]*?) in view (?:<[^>]*>)*([^<\s]*)`)
+	dumpFilenameRegexp   = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	formTokenRegexp      = regexp.MustCompile(`(?i)(csrf|xsrf|token|authenticity)`)
+	versionRegexp        = regexp.MustCompile(`(?i)netmagis\s+(?:version\s+)?v?([0-9]+(?:\.[0-9]+)+)`)
 )
 
 /*
@@ -33,20 +49,229 @@ func try(mapInstance map[string]interface{}, key string, defaultValue interface{
 
 // `host` can be a FQDN or an IP.
 func checkFqdn(host string) bool {
-	return fqdnRegexp.Match([]byte(host))
+	return fqdnRegexp.Match([]byte(normalizeFqdn(host)))
+}
+
+// Return `fqdn` lowercased (DNS names are case-insensitive and Netmagis stores
+// them lowercased) and without the trailing dot of its absolute form (valid in DNS
+// but not accepted by Netmagis forms).
+func normalizeFqdn(fqdn string) string {
+	return strings.ToLower(strings.TrimSuffix(fqdn, "."))
 }
 
 func checkIp(host string) bool {
 	return net.ParseIP(host) != nil
 }
 
+// Return the canonical form of the address `ip` (e.g. `2001:db8::1` for
+// `2001:0db8:0:0:0:0:0:1`, `192.0.2.1` for `::ffff:192.0.2.1`). Unspecified,
+// loopback and multicast addresses are rejected unless `allowSpecial` is set.
+func CanonicalIP(ip string, allowSpecial bool) (string, error) {
+	parsedIp := net.ParseIP(strings.TrimSpace(ip))
+	if parsedIp == nil {
+		return "", &NetmagisError{msg: fmt.Sprintf("invalid IP address '%s'", ip)}
+	}
+	if !allowSpecial {
+		special := ""
+		switch {
+		case parsedIp.IsUnspecified():
+			special = "unspecified"
+		case parsedIp.IsLoopback():
+			special = "loopback"
+		case parsedIp.IsMulticast():
+			special = "multicast"
+		}
+		if special != "" {
+			return "", &NetmagisError{msg: fmt.Sprintf("%s IP address '%s' not allowed", special, ip)}
+		}
+	}
+	return parsedIp.String(), nil
+}
+
+// Check that `value` is a bare mail address (without display name).
+func checkMail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+// Reject form values containing control characters (CR, LF, NUL, ...), which are
+// never valid in Netmagis fields and would corrupt the database or lead to
+// confusing server errors.
+func checkFormValues(formData url.Values) error {
+	for field, values := range formData {
+		for _, value := range values {
+			if index := strings.IndexFunc(value, unicode.IsControl); index != -1 {
+				char, _ := utf8.DecodeRuneInString(value[index:])
+				return &NetmagisError{
+					msg: fmt.Sprintf(
+						"invalid value for field '%s': control character %q at position %d",
+						field, char, utf8.RuneCountInString(value[:index]),
+					),
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func splitFqdn(fqdn string) (string, string) {
+	fqdn = normalizeFqdn(fqdn)
 	res := strings.SplitN(fqdn, ".", 2)
 	return res[0], res[1]
 }
 
+// Split `fqdn` on the longest matching zone of `zones`, falling back to a split on
+// the first dot when no zone matches.
+func splitFqdnZone(fqdn string, zones []string) (string, string) {
+	fqdn = normalizeFqdn(fqdn)
+	domain := ""
+	for _, zone := range zones {
+		zone = strings.Trim(zone, ".")
+		if strings.HasSuffix(fqdn, "."+zone) && len(zone) > len(domain) {
+			domain = zone
+		}
+	}
+	if domain == "" {
+		return splitFqdn(fqdn)
+	}
+	return strings.TrimSuffix(fqdn, "."+domain), domain
+}
+
+// Clean a value scraped from a page: surrounding whitespaces are removed, HTML
+// entities left encoded by Netmagis (e.g. `&eacute;`) are decoded and invalid UTF-8
+// sequences are replaced (see sanitizeBody).
+func cleanText(text string) string {
+	return html.UnescapeString(strings.TrimSpace(strings.ToValidUTF8(text, invalidUTF8Replacement)))
+}
+
+// Replacement of the invalid UTF-8 sequences of the pages.
+const invalidUTF8Replacement = "\uFFFD"
+
+// Replace the invalid UTF-8 sequences of the page `body` (e.g. a comment saved in
+// another encoding), so that scraped values can always be encoded (JSON, YAML,
+// ...). In strict mode (see WithStrictUTF8), an error is returned instead.
+func (c *NetmagisClient) sanitizeBody(uri string, body []byte) ([]byte, error) {
+	if utf8.Valid(body) {
+		return body, nil
+	}
+	if c.strictUTF8 {
+		return nil, &NetmagisError{
+			msg:  fmt.Sprintf("ValidationError: %s answer is not valid UTF-8", uri),
+			kind: ErrValidation,
+		}
+	}
+	return bytes.ToValidUTF8(body, []byte(invalidUTF8Replacement)), nil
+}
+
+// Return the query of the link to the next page of a paginated listing, if any.
+func nextPageQuery(doc *html.Node) (url.Values, bool) {
+	next := htmlquery.FindOne(
+		doc, "//a[contains(., 'Next') or contains(., 'next') or contains(., '>>')]",
+	)
+	if next == nil {
+		return nil, false
+	}
+	nextUrl, err := url.Parse(htmlquery.SelectAttr(next, "href"))
+	if err != nil || len(nextUrl.Query()) == 0 {
+		return nil, false
+	}
+	return nextUrl.Query(), true
+}
+
+// Return the host (and port) part of `rawUrl`.
+func urlHost(rawUrl string) (string, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	return parsedUrl.Host, nil
+}
+
+// Set the query parameter `key` of `rawUrl` to `value`.
+func setQueryParam(rawUrl string, key string, value string) (string, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	query := parsedUrl.Query()
+	query.Set(key, value)
+	parsedUrl.RawQuery = query.Encode()
+	return parsedUrl.String(), nil
+}
+
 func nodeText(node *html.Node) string {
-	return strings.TrimSpace(htmlquery.InnerText(node))
+	return cleanText(htmlquery.InnerText(node))
+}
+
+// Return the text of `node`, with a line per text node (e.g. the lines of a cell
+// whose items are separated by `<br>`).
+func nodeLines(node *html.Node) string {
+	lines := []string{}
+	for _, text := range htmlquery.Find(node, "//text()") {
+		lines = append(lines, cleanText(text.Data))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Split a multi-value field (aliases, groups, addresses, ...) on any run of
+// whitespaces and commas, as the separator depends on the Netmagis version.
+func splitValues(value string) []string {
+	values := []string{}
+	for _, item := range valueSeparatorRegexp.Split(value, -1) {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// Normalize a field label into a stable key: HTML entities are decoded, parens and
+// trailing colons removed and whitespaces (including non-breaking spaces) collapsed
+// into underscores.
+func normalizeLabel(label string) string {
+	label = html.UnescapeString(label)
+	label = strings.ReplaceAll(label, "\u00a0", " ")
+	label = strings.ReplaceAll(label, "(", "")
+	label = strings.ReplaceAll(label, ")", "")
+	label = strings.TrimRight(strings.TrimSpace(label), ":")
+	return strings.ToLower(strings.Join(strings.Fields(label), "_"))
+}
+
+// Text of a table cell, or the value of the form field it contains.
+func cellText(node *html.Node) string {
+	if field := htmlquery.FindOne(node, "//input|//textarea"); field != nil {
+		if field.Data == "textarea" {
+			return nodeText(field)
+		}
+		return htmlquery.SelectAttr(field, "value")
+	}
+	return nodeText(node)
+}
+
+// Parse an HTML table whose first row contains the headers. Each following row is
+// returned as a map from the normalized header (see normalizeLabel) to the cell
+// content.
+func parseTable(table *html.Node) []map[string]string {
+	rows := []map[string]string{}
+	headers := []string{}
+	for idx, tr := range htmlquery.Find(table, "//tr") {
+		cells := htmlquery.Find(tr, "//th|//td")
+		if idx == 0 {
+			for _, cell := range cells {
+				headers = append(headers, normalizeLabel(nodeText(cell)))
+			}
+			continue
+		}
+
+		row := map[string]string{}
+		for cellIdx, cell := range cells {
+			if cellIdx < len(headers) {
+				row[headers[cellIdx]] = cellText(cell)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
 }
 
 func intToStr(value interface{}) string {
@@ -73,7 +298,7 @@ func strToInt(value interface{}) (int, error) {
 
 		i, err := strconv.Atoi(v)
 		if err != nil {
-			return -999, &NetmagisError{fmt.Sprintf("conversion error: %s", err.Error())}
+			return -999, &NetmagisError{msg: fmt.Sprintf("conversion error: %s", err.Error())}
 		}
 
 		return i, nil
@@ -91,6 +316,9 @@ func boolToStr(value interface{}) string {
 	return value.(string)
 }
 
+// Values of the SMTP emit right displayed by the search page.
+var smtpRightValues = map[string]bool{"yes": true, "oui": true, "no": false, "non": false}
+
 func strToBool(value interface{}) bool {
 	if v, ok := value.(string); ok {
 		if v == "1" {
@@ -101,92 +329,473 @@ func strToBool(value interface{}) bool {
 	return value.(bool)
 }
 
+/*
+ * Host
+ */
+
+// Host parameters, as returned by Search and GetHost and accepted (as params) by
+// AddHost and UpdateHost. The `sendsmtp` field is a bool whatever the source (the
+// /mod form checkbox for GetHost, the `smtp_emit_right` field for Search).
+type Host map[string]interface{}
+
+// Return whether the host is allowed to emit SMTP (`sendsmtp` field), also
+// accepting the "1"/"0" strings of the form values.
+func (host Host) SendSMTP() bool {
+	switch value := host["sendsmtp"].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "1"
+	}
+	return false
+}
+
+// Type of the record matched by a search (exposed in the `record_type` field).
+type RecordType string
+
+const (
+	RecordTypeHost    RecordType = "host"
+	RecordTypeAlias   RecordType = "alias"
+	RecordTypePtr     RecordType = "ptr"
+	RecordTypeUnknown RecordType = "unknown"
+)
+
+// Classify the record from the "is a ... in view" sentence of a search result.
+func parseRecordType(description string) RecordType {
+	description = strings.ToLower(description)
+	switch {
+	case strings.Contains(description, "alias"):
+		return RecordTypeAlias
+	case strings.Contains(description, "address"), strings.Contains(description, "reverse"):
+		return RecordTypePtr
+	case strings.Contains(description, "host"):
+		return RecordTypeHost
+	}
+	return RecordTypeUnknown
+}
+
 /*
  * Client
  */
 type NetmagisClient struct {
 	BaseUrl    string
 	HttpClient *HttpClient
+
+	auditHandler       func(AuditEntry)
+	batchItemTimeout   time.Duration
+	casMaxRedirects    int
+	casService         string
+	casTLSConfig       *tls.Config
+	clock              Clock
+	retries            int
+	retryBackoff       time.Duration
+	ctx                context.Context
+	debugDir           string
+	defaultParams      map[string]interface{}
+	defaultView        string
+	endpoints          Endpoints
+	forceSteps         bool
+	forms              *formCache
+	headers            http.Header
+	interceptor        ResponseInterceptor
+	jar                http.CookieJar
+	lenient            bool
+	markers            Markers
+	metrics            Metrics
+	notFoundPatterns   []*regexp.Regexp
+	organization       string
+	permissionPatterns []*regexp.Regexp
+	quotaPatterns      []*regexp.Regexp
+	rateLimiter        *rateLimiter
+	replica            *replica
+	rest               *restAPI
+	resultHandler      func(OperationResult)
+	semaphore          chan struct{}
+	strictDNS          bool
+	strictUTF8         bool
+	tlsConfig          *tls.Config
+	verifyAfterWrite   bool
+	zones              []string
+}
+
+// Connection settings of a Netmagis instance.
+type YamlProfile struct {
+	Url      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 }
 
+// Configuration file content. The `netmagis` block is the default profile, other
+// instances can be defined as named profiles:
+//
+//	netmagis:
+//	  url: https://netmagis.example.com
+//	  username: user
+//	  password: secret
+//	profiles:
+//	  staging:
+//	    url: https://netmagis-staging.example.com
+//	    username: user
+//	    password: secret
 type YamlConfig struct {
-	Netmagis struct {
-		Url      string `yaml:"url"`
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
-	}
+	Netmagis YamlProfile
+	Profiles map[string]YamlProfile `yaml:"profiles"`
 }
 
-func FromConfig(filepath string) (*NetmagisClient, error) {
+// Name of the profile defined by the `netmagis` block of the configuration.
+const DefaultProfile = "default"
+
+func FromConfig(filepath string, opts ...ClientOption) (*NetmagisClient, error) {
+	return FromConfigProfile(filepath, DefaultProfile, opts...)
+}
+
+// Same as FromConfig but use the profile `name` of the configuration file.
+func FromConfigProfile(filepath string, name string, opts ...ClientOption) (*NetmagisClient, error) {
 	config := YamlConfig{}
 
 	fileContent, err := ioutil.ReadFile(filepath)
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("FromConfig: unable to load YAML file: %s", err.Error()),
+			msg: fmt.Sprintf("FromConfig: unable to load YAML file: %s", err.Error()),
 		}
 	}
 
 	err = yaml.Unmarshal(fileContent, &config)
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("FromConfig: unable to parse YAML content: %s", err.Error()),
+			msg: fmt.Sprintf("FromConfig: unable to parse YAML content: %s", err.Error()),
 		}
 	}
 
-	if config.Netmagis.Url == "" {
-		return nil, &NetmagisError{"FromConfig: URL not defined"}
+	profile, found := config.Profiles[name]
+	if name == DefaultProfile && config.Netmagis != (YamlProfile{}) {
+		profile, found = config.Netmagis, true
+	}
+	if !found {
+		return nil, &NetmagisError{msg: fmt.Sprintf("FromConfig: profile '%s' not defined", name)}
+	}
+
+	if profile.Url == "" {
+		return nil, &NetmagisError{msg: "FromConfig: URL not defined"}
 	}
-	if config.Netmagis.Username == "" {
-		return nil, &NetmagisError{"FromConfig: username not defined"}
+	if profile.Username == "" {
+		return nil, &NetmagisError{msg: "FromConfig: username not defined"}
 	}
-	if config.Netmagis.Password == "" {
-		return nil, &NetmagisError{"FromConfig: password not defined"}
+	if profile.Password == "" {
+		return nil, &NetmagisError{msg: "FromConfig: password not defined"}
 	}
 
-	return NewClient(
-		config.Netmagis.Url, config.Netmagis.Username, config.Netmagis.Password,
-	)
+	return NewClient(profile.Url, profile.Username, profile.Password, opts...)
 }
 
 //
 // Authenticate through CAS and return initialized Client struct
 //
+// Options (see options.go) allow customizing the client before authentication.
+//
 // FIXME: implement retries on CAS auth (there was random connection problems in some
 // Python scripts that were solved by implementing retries).
 //
-func NewClient(url string, username string, password string) (*NetmagisClient, error) {
-	httpClient, err := NewHttpClient()
+func NewClient(url string, username string, password string, opts ...ClientOption) (*NetmagisClient, error) {
+	client, err := newClient(url, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get CAS URL
-	res, err := httpClient.GetRedirect(fmt.Sprintf("%s/start", url))
+	if err := client.authenticate(username, password); err != nil {
+		return nil, err
+	}
+	if err := client.connectReplica(); err != nil {
+		return nil, err
+	}
+	if err := client.resolveDefaultView(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Same as NewClient but authenticating on the CAS login URL `casUrl` (e.g.
+// `https://cas.example.com/cas/login`) instead of the one of the redirect of the
+// start page, for deployments where the redirect is not usable (e.g. an internal
+// CAS host unreachable from the client). The service is the start page of `url`,
+// unless given in `casUrl` or overridden with WithCASService.
+func NewClientWithCAS(url string, casUrl string, username string, password string, opts ...ClientOption) (*NetmagisClient, error) {
+	client, err := newClient(url, opts)
 	if err != nil {
+		return nil, err
+	}
+
+	service := client.casService
+	if service == "" {
+		if _, err := casServiceUrl(casUrl); err != nil {
+			service = client.JoinUrl(client.endpoints.Start)
+		}
+	}
+	casLoginUrl := casUrl
+	if service != "" {
+		if casLoginUrl, err = setQueryParam(casUrl, "service", service); err != nil {
+			return nil, &NetmagisError{msg: fmt.Sprintf("NewClientWithCAS: invalid CAS URL: %s", err.Error())}
+		}
+	}
+
+	if err := client.casLogin(casLoginUrl, username, password); err != nil {
+		return nil, err
+	}
+	if err := client.connectReplica(); err != nil {
+		return nil, err
+	}
+	if err := client.resolveDefaultView(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Initialize a client from a CAS service or proxy ticket issued for the Netmagis
+// service, for services acting on behalf of users without holding their password.
+// The ticket is presented to Netmagis, which validates it against CAS; a ticket
+// can only be used once. The service is the one of the CAS redirect of the start
+// page, unless overridden with WithCASService. ErrInvalidTicket is returned when
+// no session is established.
+func NewClientFromTicket(url string, ticket string, opts ...ClientOption) (*NetmagisClient, error) {
+	client, err := newClient(url, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	service := client.casService
+	if service == "" {
+		res, err := client.HttpClient.GetRedirectContext(
+			client.context(), client.JoinUrl(client.endpoints.Start),
+		)
+		if err != nil {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf("NewClientFromTicket: unable to retrieve CAS URL: %s", err.Error()),
+			}
+		}
+		res.Body.Close()
+		if service, err = casServiceUrl(res.Header.Get("Location")); err != nil {
+			return nil, &NetmagisError{msg: fmt.Sprintf("NewClientFromTicket: %s", err.Error())}
+		}
+	}
+
+	cas := CasClient{
+		HttpClient:   client.HttpClient,
+		Context:      client.context(),
+		MaxRedirects: client.casMaxRedirects,
+	}
+	if err := cas.ValidateTicket(service, ticket); err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("NewClient: unable to retrieve CAS URL: %s", err.Error()),
+			msg: fmt.Sprintf("NewClientFromTicket: ticket validation error: %s", err.Error()),
 		}
 	}
-	casLoginUrl := res.Header["Location"][0]
 
-	// Connect to Netmagis through CAS
-	cas := CasClient{LoginUrl: casLoginUrl, HttpClient: httpClient}
-	err = cas.Connect(username, password)
+	loggedIn, err := client.IsLoggedIn()
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("NewClient: CAS error: %s", err.Error()),
+			msg: fmt.Sprintf("NewClientFromTicket: unable to check session: %s", err.Error()),
 		}
 	}
+	if !loggedIn {
+		return nil, ErrInvalidTicket
+	}
+	if err := client.connectReplica(); err != nil {
+		return nil, err
+	}
+	if err := client.resolveDefaultView(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
 
-	// Return client
+// Initialize a client, not yet authenticated, from its options.
+func newClient(url string, opts []ClientOption) (*NetmagisClient, error) {
 	client := &NetmagisClient{
-		BaseUrl:    url,
-		HttpClient: httpClient,
+		BaseUrl:            url,
+		endpoints:          DefaultEndpoints,
+		markers:            DefaultMarkers,
+		quotaPatterns:      DefaultQuotaPatterns,
+		notFoundPatterns:   DefaultNotFoundPatterns,
+		permissionPatterns: DefaultPermissionPatterns,
+		semaphore:          make(chan struct{}, 1),
+		forms:              &formCache{forms: map[string]*formInfo{}},
+	}
+	for _, opt := range opts {
+		opt(client)
 	}
+
+	httpClient, err := NewHttpClient()
+	if err != nil {
+		return nil, err
+	}
+	if client.jar != nil {
+		httpClient.HttpClient.Jar = client.jar
+	}
+	httpClient.Headers = client.headers
+	httpClient.Retries = client.retries
+	httpClient.RetryBackoff = client.retryBackoff
+	httpClient.Clock = client.clock
+	if client.tlsConfig != nil || client.casTLSConfig != nil {
+		host, err := urlHost(url)
+		if err != nil {
+			return nil, &NetmagisError{msg: fmt.Sprintf("NewClient: invalid URL: %s", err.Error())}
+		}
+		casTLSConfig := client.casTLSConfig
+		if casTLSConfig == nil {
+			casTLSConfig = client.tlsConfig
+		}
+		httpClient.HttpClient.Transport = newSplitTLSTransport(
+			host, client.tlsConfig, casTLSConfig,
+		)
+	}
+	client.HttpClient = httpClient
 	return client, nil
 }
 
+// Authenticate the client through CAS. The CAS login URL is retrieved from the
+// redirection of the start page. When the start page does not redirect, the client
+// is considered authenticated if it already has a valid session (e.g. when sharing
+// a cookie jar).
+func (c *NetmagisClient) authenticate(username string, password string) error {
+	// Get CAS URL
+	res, err := c.HttpClient.getRetry(c.context(), c.JoinUrl(c.endpoints.Start))
+	if err != nil {
+		return &NetmagisError{
+			msg: fmt.Sprintf("NewClient: unable to retrieve CAS URL: %s", err.Error()),
+		}
+	}
+	if res.StatusCode != 301 && res.StatusCode != 302 && res.StatusCode != 200 {
+		return &NetmagisError{
+			msg: fmt.Sprintf(
+				"NewClient: unable to retrieve CAS URL: %s",
+				unexpectedStatusError(res, "30{1,2} expected").Error(),
+			),
+		}
+	}
+	res.Body.Close()
+
+	if res.StatusCode == 200 {
+		loggedIn, err := c.IsLoggedIn()
+		if err != nil {
+			return &NetmagisError{
+				msg: fmt.Sprintf("NewClient: unable to check session: %s", err.Error()),
+			}
+		}
+		if !loggedIn {
+			return &NetmagisError{
+				msg: "NewClient: start page did not redirect to CAS and no valid session found",
+			}
+		}
+		return nil
+	}
+	if res.Header.Get("Location") == "" {
+		return &NetmagisError{
+			msg: fmt.Sprintf(
+				"NewClient: unable to retrieve CAS URL: no location in redirect (status: %s)",
+				res.Status,
+			),
+		}
+	}
+	casLoginUrl := res.Header.Get("Location")
+	if c.casService != "" {
+		if casLoginUrl, err = setQueryParam(casLoginUrl, "service", c.casService); err != nil {
+			return &NetmagisError{msg: fmt.Sprintf("NewClient: invalid CAS URL: %s", err.Error())}
+		}
+	}
+	return c.casLogin(casLoginUrl, username, password)
+}
+
+// Connect to Netmagis through the CAS login URL `casLoginUrl`.
+func (c *NetmagisClient) casLogin(casLoginUrl string, username string, password string) error {
+	cas := CasClient{
+		LoginUrl:     casLoginUrl,
+		HttpClient:   c.HttpClient,
+		Context:      c.context(),
+		MaxRedirects: c.casMaxRedirects,
+	}
+	if err := cas.Connect(username, password); err != nil {
+		return &NetmagisError{
+			msg: fmt.Sprintf("NewClient: CAS error: %s", err.Error()),
+			err: err,
+		}
+	}
+
+	return nil
+}
+
+// Check whether the client has a valid Netmagis session: the profile page is
+// served instead of redirecting to CAS.
+func (c *NetmagisClient) IsLoggedIn() (bool, error) {
+	res, err := c.HttpClient.GetContext(c.context(), c.JoinUrl(c.endpoints.Profile))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	return res.StatusCode == 200, nil
+}
+
+// Return a shallow copy of the client whose operations are bound to `ctx`. This
+// is the per-call counterpart of WithBaseContext and takes precedence over it:
+//
+//	host, err := client.WithContext(ctx).GetHost("host.example.com")
+func (c *NetmagisClient) WithContext(ctx context.Context) *NetmagisClient {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// Return a shallow copy of the client whose mutating operations are scoped to the
+// organization `organization`, taking precedence over WithOrganization.
+func (c *NetmagisClient) ForOrganization(organization string) *NetmagisClient {
+	clone := *c
+	clone.organization = organization
+	return &clone
+}
+
+// Return a shallow copy of the client whose requests use `timeout` instead of the
+// client timeout (60 seconds by default), e.g. for crawling many pages:
+//
+//	hosts, err := client.WithTimeout(10 * time.Minute).ListHosts()
+//
+// The timeout applies to each HTTP request and replaces the client timeout, so it
+// can be longer or shorter. A context deadline (see WithContext) still applies on
+// top of it: the shortest one wins.
+func (c *NetmagisClient) WithTimeout(timeout time.Duration) *NetmagisClient {
+	httpClient := *c.HttpClient
+	client := *httpClient.HttpClient
+	client.Timeout = timeout
+	httpClient.HttpClient = &client
+
+	clone := *c
+	clone.HttpClient = &httpClient
+	return &clone
+}
+
+// Context used for requests: the per-call context (WithContext) or the base
+// context (WithBaseContext), context.Background() otherwise.
+func (c *NetmagisClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Split `fqdn` into name and domain according to the managed zones of the client
+// (see WithZones and ResolveZones).
+func (c *NetmagisClient) splitFqdn(fqdn string) (string, string) {
+	return splitFqdnZone(fqdn, c.zones)
+}
+
+// Retrieve the managed zones from Netmagis (see ListDomains) and use them for
+// splitting FQDNs into name and domain.
+func (c *NetmagisClient) ResolveZones() error {
+	domains, err := c.ListDomains()
+	if err != nil {
+		return err
+	}
+	c.zones = domains
+	return nil
+}
+
 func (c *NetmagisClient) JoinUrl(paths ...string) string {
 	url := c.BaseUrl
 	for _, path := range paths {
@@ -195,32 +804,324 @@ func (c *NetmagisClient) JoinUrl(paths ...string) string {
 	return url
 }
 
+// Post `formData` to `uri` and return the response body, checked with
+// `validateFunc`. HTTP errors are retried according to WithRetries, so mutating
+// forms should rather be submitted through `submit`.
 func (c *NetmagisClient) Call(uri string, formData url.Values, validateFunc func(body string) bool) (string, error) {
-	res, err := c.HttpClient.PostForm(c.JoinUrl(uri), formData)
+	var body string
+	err := c.retry(func() error {
+		var err error
+		body, err = c.call(uri, formData, validateFunc)
+		return err
+	})
+	return body, err
+}
+
+// Single attempt of Call, reported to the metrics of the client (see WithMetrics).
+func (c *NetmagisClient) call(uri string, formData url.Values, validateFunc func(body string) bool) (string, error) {
+	operation := operationName(uri, formData)
+	metrics := c.metricsOrDefault()
+	metrics.CallStarted(operation)
+	start := c.clockOrDefault().Now()
+
+	body, err := c.send(uri, formData, validateFunc)
+	metrics.CallFinished(operation, c.clockOrDefault().Now().Sub(start), ErrorKindLabel(err))
+	if netmagisErr, ok := err.(*NetmagisError); ok && netmagisErr.op == "" {
+		netmagisErr.op = operation
+	}
+	return body, err
+}
+
+func (c *NetmagisClient) send(uri string, formData url.Values, validateFunc func(body string) bool) (string, error) {
+	if err := checkFormValues(formData); err != nil {
+		return "", err
+	}
+	if err := c.waitRateLimit(); err != nil {
+		return "", err
+	}
+	res, err := c.HttpClient.PostFormContext(c.context(), c.requestUrl(uri), formData)
 	if err != nil {
-		return "", &NetmagisError{fmt.Sprintf("ClientError: %s", err.Error())}
+		c.intercept(uri, nil, nil, err)
+		return "", &NetmagisError{
+			msg:  fmt.Sprintf("ClientError: %s", err.Error()),
+			kind: ErrTransport,
+			err:  err,
+		}
 		//return &NetmagisError{fmt.Sprintf("%s: HTTP request error: %s", name, err.Error())}
 	}
-	body, _ := c.HttpClient.ReadBody(res)
-	bodyString := string(body)
+	defer res.Body.Close()
+	body, err := c.HttpClient.ReadBody(res)
+	c.dumpResponse(uri, body)
+	c.intercept(uri, res, body, err)
+	if err != nil {
+		return "", err
+	}
+
+	body, err = c.sanitizeBody(uri, body)
+	if err != nil {
+		return "", err
+	}
+	bodyString := string(body)
+
+	if strings.Contains(bodyString, "<h2>Error!</h2>") {
+		page := parseErrorPage(bodyString)
+		return "", &NetmagisError{
+			msg:  fmt.Sprintf("NetmagisError: %s", page.Message),
+			kind: c.errorKind(page.Message),
+			page: page,
+		}
+	}
+
+	if !validateFunc(bodyString) {
+		return "", &NetmagisError{
+			msg: fmt.Sprintf(
+				"ValidationError: unexpected output (raw HTML answer for debug): %s", body,
+			),
+			kind: ErrValidation,
+		}
+	}
+	return bodyString, nil
+}
+
+// Return the kind of the Netmagis error message `errorMsg` (nil for a generic error).
+func (c *NetmagisClient) errorKind(errorMsg string) error {
+	for _, pattern := range c.quotaPatterns {
+		if pattern.MatchString(errorMsg) {
+			return ErrQuotaExceeded
+		}
+	}
+	for _, pattern := range c.permissionPatterns {
+		if pattern.MatchString(errorMsg) {
+			return ErrPermissionDenied
+		}
+	}
+	if c.isNotFound(errorMsg) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Report whether `text` (a page or an error message) says that a name does not
+// exist (see WithNotFoundPatterns). HTML entities are decoded first, as accented
+// letters of localized messages may be encoded (e.g. `cha&icirc;ne`).
+func (c *NetmagisClient) isNotFound(text string) bool {
+	text = html.UnescapeString(text)
+	for _, pattern := range c.notFoundPatterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pass the response of a request to `uri` to the response interceptor, if any (see
+// WithResponseInterceptor). The interceptor gets a copy of the body, so it can't
+// alter the parsed content.
+func (c *NetmagisClient) intercept(uri string, res *http.Response, body []byte, err error) {
+	if c.interceptor == nil {
+		return
+	}
+	var req *http.Request
+	if res != nil {
+		req = res.Request
+	} else {
+		req, _ = http.NewRequestWithContext(c.context(), http.MethodPost, c.requestUrl(uri), nil)
+	}
+	c.interceptor(req, res, append([]byte(nil), body...), err)
+}
+
+// Write a raw response to the debug directory (see WithDebugDump). Errors are
+// ignored as dumps are only a debugging aid.
+func (c *NetmagisClient) dumpResponse(uri string, body []byte) {
+	if c.debugDir == "" {
+		return
+	}
+	filename := fmt.Sprintf(
+		"%s_%s.html",
+		c.clockOrDefault().Now().Format("20060102T150405.000000000"),
+		strings.Trim(dumpFilenameRegexp.ReplaceAllString(uri, "_"), "_"),
+	)
+	ioutil.WriteFile(filepath.Join(c.debugDir, filename), body, 0600)
+}
+
+// Form served at an endpoint, discovered before submitting it (see addFormTokens).
+type formInfo struct {
+	// Anti-CSRF hidden fields of the form
+	tokens url.Values
+	// Names of the fields of the form
+	fields map[string]bool
+}
+
+// Forms discovered during the session, shared by the copies of the client. A form
+// without token is only loaded once; a form with tokens is loaded again before each
+// submission, as tokens may be single-use.
+type formCache struct {
+	mutex sync.Mutex
+	forms map[string]*formInfo
+}
+
+// Fetch the form served at `uri` and return the anti-CSRF hidden fields (if any)
+// it contains, so they can be posted back with the form data.
+func (c *NetmagisClient) FormTokens(uri string, query url.Values) (url.Values, error) {
+	_, info, err := c.loadForm(uri, query)
+	if err != nil {
+		return nil, err
+	}
+	return info.tokens, nil
+}
+
+// Fetch and parse the form served at `uri`, recording it in the form cache.
+func (c *NetmagisClient) loadForm(uri string, query url.Values) (*html.Node, *formInfo, error) {
+	body, err := c.Call(uri, query, func(body string) bool { return true })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse %s HTML response: %s", uri, err.Error()),
+		}
+	}
+
+	info := &formInfo{tokens: url.Values{}, fields: map[string]bool{}}
+	for _, node := range htmlquery.Find(doc, "//input | //select | //textarea") {
+		inputName := htmlquery.SelectAttr(node, "name")
+		if inputName == "" {
+			continue
+		}
+		info.fields[inputName] = true
+		if node.Data == "input" && strings.EqualFold(htmlquery.SelectAttr(node, "type"), "hidden") &&
+			formTokenRegexp.MatchString(inputName) {
+			info.tokens.Set(inputName, htmlquery.SelectAttr(node, "value"))
+		}
+	}
+
+	if c.forms != nil {
+		c.forms.mutex.Lock()
+		c.forms.forms[uri] = info
+		c.forms.mutex.Unlock()
+	}
+	return doc, info, nil
+}
+
+// Return the form served at `uri`, from the form cache when it has no token.
+func (c *NetmagisClient) discoverForm(uri string, query url.Values) (*formInfo, error) {
+	if c.forms != nil {
+		c.forms.mutex.Lock()
+		info, found := c.forms.forms[uri]
+		c.forms.mutex.Unlock()
+		if found && len(info.tokens) == 0 {
+			return info, nil
+		}
+	}
+	_, info, err := c.loadForm(uri, query)
+	return info, err
+}
+
+// Complete `formData` with the fields expected by the form served at `uri`: its
+// anti-CSRF tokens, the values of the CSRF cookies (see HttpClient.CSRFCookies) for
+// the fields named after them and left empty by the form (they are filled by a
+// script in a browser) and the organization of the client (see WithOrganization)
+// when it has an organization field. The form is loaded once per session when it
+// has no token (see formCache). When it can't be loaded, the form data is
+// submitted as is: the instance rejects it if it actually requires a token.
+func (c *NetmagisClient) prepareForm(uri string, query url.Values, formData url.Values) {
+	c.markWrite()
+	info, err := c.onPrimary().discoverForm(uri, query)
+	if err != nil {
+		return
+	}
+	for name, values := range info.tokens {
+		formData[name] = values
+	}
+	if c.organization != "" && info.fields[organizationField] && formData.Get(organizationField) == "" {
+		formData.Set(organizationField, c.organization)
+	}
+
+	if baseUrl, err := url.Parse(c.JoinUrl(uri)); err == nil {
+		for _, cookie := range c.HttpClient.CSRFCookies(baseUrl) {
+			if info.fields[cookie.Name] && formData.Get(cookie.Name) == "" {
+				formData.Set(cookie.Name, cookie.Value)
+			}
+		}
+	}
+}
+
+// Return a shallow copy of the client sending the double-submit CSRF cookies set
+// for `uri` as headers (see HttpClient.CSRFCookies), for a mutating request.
+func (c *NetmagisClient) withCSRFHeaders(uri string) *NetmagisClient {
+	baseUrl, err := url.Parse(c.JoinUrl(uri))
+	if err != nil {
+		return c
+	}
+	cookies := c.HttpClient.CSRFCookies(baseUrl)
+	if len(cookies) == 0 {
+		return c
+	}
+
+	httpClient := *c.HttpClient
+	httpClient.Headers = http.Header{}
+	for name, values := range c.HttpClient.Headers {
+		httpClient.Headers[name] = append([]string{}, values...)
+	}
+	for _, cookie := range cookies {
+		httpClient.Headers.Set(csrfHeader(cookie.Name), cookie.Value)
+	}
+	clone := *c
+	clone.HttpClient = &httpClient
+	return &clone
+}
+
+// Informations about the Netmagis server.
+type ServerInfo struct {
+	// Version of Netmagis (empty when not exposed by the server).
+	Version string
+}
+
+// Retrieve informations about the Netmagis server from the footer of the index
+// page. An empty ServerInfo is returned when the version is not exposed.
+func (c *NetmagisClient) GetServerInfo() (*ServerInfo, error) {
+	body, err := c.Call(c.endpoints.Index, url.Values{}, func(body string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ServerInfo{}
+	if submatch := versionRegexp.FindStringSubmatch(body); submatch != nil {
+		info.Version = submatch[1]
+	}
+	return info, nil
+}
+
+// List the domains the user is allowed to manage, from the domain select of the
+// /add form.
+func (c *NetmagisClient) ListDomains() ([]string, error) {
+	body, err := c.Call(c.endpoints.Add, url.Values{}, func(body string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
 
-	if strings.Contains(bodyString, "<h2>Error!</h2>") {
-		errorMsg := strings.Trim(string(errorRegexp.FindSubmatch(body)[1]), `"`)
-		return "", &NetmagisError{fmt.Sprintf("NetmagisError: %s", errorMsg)}
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse /add HTML response: %s", err.Error()),
+		}
 	}
 
-	if !validateFunc(bodyString) {
-		return "", &NetmagisError{
-			fmt.Sprintf(
-				"ValidationError: unexpected output (raw HTML answer for debug): %s", body,
-			),
+	domains := []string{}
+	for _, node := range htmlquery.Find(doc, "//select[@name='domain']/option") {
+		domain := htmlquery.SelectAttr(node, "value")
+		if domain == "" {
+			domain = nodeText(node)
 		}
+		domains = append(domains, domain)
 	}
-	return bodyString, nil
+	return domains, nil
 }
 
 func (c *NetmagisClient) UserInfo() (map[string]string, error) {
-	body, err := c.Call("/profile", url.Values{}, func(body string) bool { return true })
+	body, err := c.Call(c.endpoints.Profile, url.Values{}, func(body string) bool { return true })
 	if err != nil {
 		return nil, err
 	}
@@ -228,7 +1129,7 @@ func (c *NetmagisClient) UserInfo() (map[string]string, error) {
 	doc, err := htmlquery.Parse(strings.NewReader(body))
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("unable to parse /profile HTML response: %s", err.Error()),
+			msg: fmt.Sprintf("unable to parse /profile HTML response: %s", err.Error()),
 		}
 	}
 
@@ -249,54 +1150,159 @@ func (c *NetmagisClient) UserInfo() (map[string]string, error) {
 	return user, nil
 }
 
-func (c *NetmagisClient) Search(host string) (map[string]interface{}, error) {
+// Search a host and return the first matching entry (see SearchAll).
+func (c *NetmagisClient) Search(host string) (Host, error) {
+	hosts, err := c.SearchAll(host)
+	if err != nil || len(hosts) == 0 {
+		return nil, err
+	}
+	return hosts[0], nil
+}
+
+// Search a host and return all matching entries (an IP address may for example
+// be shared by several names, or a name be declared in several views). The view of
+// each entry is given in its `view` field. Return an empty slice when nothing is
+// found. `host` is an IP address, a FQDN or the name of a SRV record (e.g.
+// `_sip._tcp.example.com`).
+func (c *NetmagisClient) SearchAll(host string) ([]Host, error) {
+	host = normalizeFqdn(host)
 	// Check input host
-	if !checkIp(host) && !checkFqdn(host) {
+	if !checkIp(host) && !checkFqdn(host) && !checkSRVName(host) {
 		return nil, &NetmagisError{
-			fmt.Sprintf("host '%s' is not a FQDN or and IP address", host),
+			msg: fmt.Sprintf("host '%s' is not a FQDN or and IP address", host),
+		}
+	}
+
+	rest, err := c.useREST()
+	if err != nil {
+		return nil, err
+	}
+	if rest {
+		return c.restSearchAll(host)
+	}
+	return c.search(host)
+}
+
+// Report whether no record (host, alias, mail relay, ...) exists for `fqdn`, so
+// the name can be added. Names are searched in all the views visible to the user;
+// when `views` are given, only the records of these views are considered (a record
+// whose view is not displayed is always considered). Names not visible to the user
+// can't be detected, so adding the name may still fail.
+func (c *NetmagisClient) IsAvailable(fqdn string, views ...string) (bool, error) {
+	if !checkFqdn(fqdn) {
+		return false, &NetmagisError{msg: fmt.Sprintf("IsAvailable: '%s' is not a FQDN", fqdn)}
+	}
+	hosts, err := c.SearchAll(fqdn)
+	if err != nil {
+		return false, err
+	}
+	for _, host := range hosts {
+		view, _ := host["view"].(string)
+		if len(views) == 0 || view == "" {
+			return false, nil
+		}
+		for _, wanted := range views {
+			if strings.EqualFold(view, wanted) {
+				return false, nil
+			}
 		}
 	}
+	return true, nil
+}
+
+// Search hosts whose name starts with `query` (e.g. `web` or `web.example`), for
+// interactive lookups where the full FQDN is not known yet. Unlike Search, the
+// query is not required to be a FQDN or an IP address; it is submitted with a
+// trailing wildcard unless it already contains one. Return an empty slice when
+// nothing is found.
+func (c *NetmagisClient) SearchPrefix(query string) ([]Host, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, &NetmagisError{msg: "SearchPrefix: empty query"}
+	}
+	if !strings.Contains(query, "*") {
+		query += "*"
+	}
 
-	// Search host and parse HTML response
+	hosts, err := c.search(query)
+	if err != nil {
+		return nil, err
+	}
+	// The query can't be compared to the name for detecting aliases
+	for _, host := range hosts {
+		if host["record_type"] == RecordTypeUnknown {
+			host["is_alias"] = false
+		}
+	}
+	return hosts, nil
+}
 
+// Submit `host` to the search page and parse the matching entries.
+func (c *NetmagisClient) search(host string) ([]Host, error) {
 	checkFunc := func(body string) bool {
-		return searchRegexpValidate.MatchString(body) || hostNotFoundRegexp.MatchString(body)
+		return searchRegexpValidate.MatchString(body) || c.isNotFound(body)
 	}
-	body, err := c.Call("/search", url.Values{"q": {host}}, checkFunc)
+	body, err := c.Call(c.endpoints.Search, url.Values{"q": {host}}, checkFunc)
 	if err != nil {
 		return nil, err
 	}
-	if hostNotFoundRegexp.MatchString(body) {
-		return nil, nil
+	if c.isNotFound(body) {
+		return []Host{}, nil
 	}
 
 	doc, err := htmlquery.Parse(strings.NewReader(body))
 	if err != nil {
 		return nil, &NetmagisError{
-			fmt.Sprintf("unable to parse /search HTML response: %s", err.Error()),
+			msg: fmt.Sprintf("unable to parse /search HTML response: %s", err.Error()),
 		}
 	}
 
-	// Parse all <td> in the page to generating output. The HTML table contains two
-	// columns: field and value. The returned keys are mapped to be coherent with
-	// other API calls (but some fields like aliases and groups are not used by
-	// other calls).
-	hostParams := map[string]interface{}{}
-	nodes := htmlquery.Find(doc, "//td[@class='tab-text10']")
+	// Each match is rendered in its own HTML table, preceded by a sentence giving
+	// the type of the record and its view.
+	recordTypes, views := []RecordType{}, []string{}
+	for _, submatch := range searchRecordRegexp.FindAllStringSubmatch(body, -1) {
+		recordTypes = append(recordTypes, parseRecordType(submatch[1]))
+		views = append(views, strings.TrimSuffix(submatch[2], "."))
+	}
+
+	hosts := []Host{}
+	tables := htmlquery.Find(
+		doc,
+		"//table[tr/td[@class='tab-text10'] or tbody/tr/td[@class='tab-text10']]",
+	)
+	for idx, table := range tables {
+		recordType := RecordTypeUnknown
+		if idx < len(recordTypes) {
+			recordType = recordTypes[idx]
+		}
+		hostParams := parseSearchTable(table, host, recordType)
+		if idx < len(views) {
+			hostParams["view"] = views[idx]
+		}
+		hosts = append(hosts, hostParams)
+	}
+	return hosts, nil
+}
+
+// Parse all <td> of a search result table to generating output. The HTML table
+// contains two columns: field and value. The returned keys are mapped to be
+// coherent with other API calls (but some fields like aliases and groups are not
+// used by other calls).
+func parseSearchTable(table *html.Node, host string, recordType RecordType) Host {
+	hostParams := Host{}
+	nodes := htmlquery.Find(table, "//td[@class='tab-text10']")
 	field := ""
 	for idx, node := range nodes {
 		if idx%2 == 0 {
-			field = nodeText(node)
-			field = strings.ReplaceAll(field, "(", "")
-			field = strings.ReplaceAll(field, ")", "")
-			field = strings.ReplaceAll(field, " ", "_")
-			field = strings.ToLower(field)
+			field = normalizeLabel(nodeText(node))
 		} else {
 			value := nodeText(node)
 
 			switch field {
 			case "smtp_emit_right":
-				hostParams[field] = map[string]bool{"Yes": true, "No": false, "": false}[value]
+				// Same field as the checkbox of the /mod form (see GetHost)
+				hostParams[field] = smtpRightValues[strings.ToLower(value)]
+				hostParams["sendsmtp"] = hostParams[field]
 			case "dhcp_profile":
 				profile := ""
 				if value != "No profile" {
@@ -306,28 +1312,103 @@ func (c *NetmagisClient) Search(host string) (map[string]interface{}, error) {
 			case "ttl":
 				hostParams[field] = func() int { v, _ := strconv.Atoi(value); return v }()
 			case "aliases", "allowed_groups":
-				hostParams[field] = strings.Split(value, " ")
-				hostParams[field] = strings.Split(value, " ")
+				// Items may be separated by line breaks, whose text is not kept
+				hostParams[field] = splitValues(nodeLines(node))
+			case "srv":
+				// SRV records are separated by line breaks
+				hostParams[field] = strings.TrimSpace(nodeLines(node))
+			case "ip_addresses":
+				// Addresses of round-robin names are separated by line breaks
+				hostParams[field] = strings.Join(splitValues(nodeLines(node)), "\n")
 			default:
-				hostParams[field] = value
+				if !setTimestamp(hostParams, field, value) {
+					hostParams[field] = value
+				}
 			}
 
 			field = ""
 		}
 	}
-	// Computed field indicating if the entry is an alias
-	hostParams["is_alias"] = host != hostParams["name"]
+	setMissingTimestamps(hostParams)
 
-	return hostParams, nil
+	// Number of addresses of the name (more than one for round-robin DNS)
+	hostParams["naddr"] = len(searchValues(hostParams, "ip_addresses", "ip_address"))
+
+	// Computed fields indicating the type of the entry. When searching an alias,
+	// Netmagis returns the host it points to.
+	hostParams["record_type"] = recordType
+	switch recordType {
+	case RecordTypeAlias:
+		hostParams["is_alias"] = true
+	case RecordTypeHost, RecordTypePtr:
+		hostParams["is_alias"] = false
+	default:
+		name, _ := hostParams["name"].(string)
+		hostParams["is_alias"] = !strings.EqualFold(host, name)
+	}
+
+	return hostParams
+}
+
+// Set the `created` or `modified` (and `modified_by`) field of `hostParams` when
+// `field` is the label of a timestamp, reporting whether it is.
+func setTimestamp(hostParams Host, field string, value string) bool {
+	switch field {
+	case "last_modification", "modification_date", "modified", "date":
+		hostParams["modified"], hostParams["modified_by"] = parseNetmagisDate(value)
+	case "creation", "creation_date", "created":
+		hostParams["created"], _ = parseNetmagisDate(value)
+	default:
+		return false
+	}
+	return true
+}
+
+// Set the timestamps displayed in the label/value rows of `node` (see setTimestamp).
+func parseTimestamps(node *html.Node, hostParams Host) {
+	for _, row := range htmlquery.Find(node, "//tr") {
+		cells := htmlquery.Find(row, "./td|./th")
+		if len(cells) == 2 {
+			setTimestamp(hostParams, normalizeLabel(nodeText(cells[0])), nodeText(cells[1]))
+		}
+	}
+}
+
+// Set the timestamps not displayed to the zero time.
+func setMissingTimestamps(hostParams Host) {
+	for _, field := range []string{"created", "modified"} {
+		if _, found := hostParams[field]; !found {
+			hostParams[field] = time.Time{}
+		}
+	}
 }
 
 // Parse /mod form to retrieve informations about a host.
-func (c *NetmagisClient) GetHost(fqdn string) (map[string]interface{}, error) {
-	name, domain := splitFqdn(fqdn)
+//
+// When Netmagis returns several editable records for the name (e.g. one per view),
+// the record to parse is selected with `selector`: an IP address of the record,
+// a record type (`A` or `AAAA`) or a view name. Without selector, an error of kind
+// ErrAmbiguous listing the candidates is returned. A selector matching no record
+// is reported with an error of kind ErrNotFound.
+//
+// The creation and modification timestamps displayed with the form are given in
+// the `created` and `modified` (with `modified_by`) fields, zero when not displayed.
+// A select of the form without selected option is reported as an ErrValidation
+// error, its value being unknown.
+func (c *NetmagisClient) GetHost(fqdn string, selector ...string) (Host, error) {
+	fqdn = normalizeFqdn(fqdn)
+	name, domain := c.splitFqdn(fqdn)
+	rest, err := c.useREST()
+	if err != nil {
+		return nil, err
+	}
+	if rest {
+		return c.restGetHost(fqdn, selector)
+	}
 
 	// Get host modification form
 	body, err := c.Call(
-		"/mod",
+		c.endpoints.Mod,
 		url.Values{
 			"action": {"edit"},
 			"name":   {name},
@@ -337,9 +1418,9 @@ func (c *NetmagisClient) GetHost(fqdn string) (map[string]interface{}, error) {
 	)
 	if err != nil {
 		// Bypass the error returned by Netmagis for returning nil when the host
-		// does not exists.
-		hostNotFoundRegexp := regexp.MustCompile(`Name '[^']*' does not exist`)
-		if hostNotFoundRegexp.MatchString(err.Error()) {
+		// does not exists. A host the user has no rights on is reported with an
+		// error of kind ErrPermissionDenied.
+		if errors.Is(err, ErrNotFound) {
 			return nil, nil
 		}
 		return nil, err
@@ -349,12 +1430,89 @@ func (c *NetmagisClient) GetHost(fqdn string) (map[string]interface{}, error) {
 	doc, err := htmlquery.Parse(strings.NewReader(body))
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to parse /mod HTML response: %s", err.Error())
-		return nil, &NetmagisError{errMsg}
+		return nil, &NetmagisError{msg: errMsg}
+	}
+
+	forms := htmlquery.Find(doc, "//form[.//input[@name='idrr']]")
+	if len(forms) == 0 {
+		forms = []*html.Node{doc}
+	}
+	candidates := []hostCandidate{}
+	for _, form := range forms {
+		hostParams, err := parseHostForm(form)
+		if err != nil {
+			return nil, err
+		}
+		// The timestamps of a single record may be displayed outside its form
+		if _, found := hostParams["modified"]; !found && len(forms) == 1 {
+			parseTimestamps(doc, hostParams)
+		}
+		setMissingTimestamps(hostParams)
+		candidates = append(candidates, hostCandidate{
+			host:      hostParams,
+			addresses: findAddresses(nodeText(form)),
+		})
+	}
+	// The addresses are not displayed on the form of a single record
+	if len(selector) > 0 && selector[0] != "" && len(candidates) == 1 && len(candidates[0].addresses) == 0 {
+		host, err := c.Search(fqdn)
+		if err != nil {
+			return nil, err
+		}
+		if host != nil {
+			candidates[0].addresses = searchValues(host, "ip_addresses", "ip_address")
+		}
+	}
+	return selectCandidate(fqdn, candidates, selector)
+}
+
+// Select the record of `fqdn` matching `selector` (see GetHost).
+func selectCandidate(fqdn string, candidates []hostCandidate, selector []string) (Host, error) {
+	if len(selector) == 0 || selector[0] == "" {
+		if len(candidates) > 1 {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf(
+					"GetHost: several records for '%s', select one of: %s",
+					fqdn, describeCandidates(candidates),
+				),
+				kind: ErrAmbiguous,
+			}
+		}
+		return candidates[0].host, nil
+	}
+
+	matching := []hostCandidate{}
+	for _, candidate := range candidates {
+		if candidate.matches(selector[0]) {
+			matching = append(matching, candidate)
+		}
+	}
+	switch len(matching) {
+	case 0:
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf(
+				"GetHost: no record of '%s' matching '%s' (candidates: %s)",
+				fqdn, selector[0], describeCandidates(candidates),
+			),
+			kind: ErrNotFound,
+		}
+	case 1:
+		return matching[0].host, nil
 	}
+	return nil, &NetmagisError{
+		msg: fmt.Sprintf(
+			"GetHost: several records of '%s' matching '%s': %s",
+			fqdn, selector[0], describeCandidates(matching),
+		),
+		kind: ErrAmbiguous,
+	}
+}
 
+// Parse the inputs and selects of a /mod form.
+func parseHostForm(form *html.Node) (Host, error) {
 	// Parse form inputs
-	hostParams := map[string]interface{}{}
-	for _, node := range htmlquery.Find(doc, "//input") {
+	hostParams := Host{}
+	for _, node := range htmlquery.Find(form, "//input") {
 		inputName := htmlquery.SelectAttr(node, "name")
 		inputValue := htmlquery.SelectAttr(node, "value")
 		switch inputName {
@@ -362,57 +1520,156 @@ func (c *NetmagisClient) GetHost(fqdn string) (map[string]interface{}, error) {
 			v, err := strToInt(inputValue)
 			if err != nil {
 				return nil, &NetmagisError{
-					fmt.Sprintf("unable to convert field '%s' to int: %s", inputName, err.Error()),
+					msg: fmt.Sprintf("unable to convert field '%s' to int: %s", inputName, err.Error()),
 				}
 			}
 			hostParams[inputName] = v
 		case "sendsmtp":
-			if len(node.Attr) == 4 && node.Attr[3].Key == "checked" {
-				inputValue = "1"
-			} else {
-				inputValue = "0"
-			}
-			hostParams[inputName] = inputValue == "1"
+			hostParams[inputName] = hasAttr(node, "checked")
 		case "name", "mac", "hinfo", "comment", "respname", "respmail":
-			hostParams[inputName] = string(inputValue)
+			hostParams[inputName] = cleanText(inputValue)
 		}
 	}
 
-	// Parse form selects
-	for _, node := range htmlquery.Find(doc, "//select") {
+	// Parse form selects. The values are sent back by the updates, so a select
+	// without selected option is an error rather than defaulting to a value that
+	// would overwrite the current one (e.g. clearing the DHCP profile).
+	for _, node := range htmlquery.Find(form, "//select") {
 		selectName := htmlquery.SelectAttr(node, "name")
 		found := false
 		// Parse options
 		for _, o := range htmlquery.Find(node, "//option") {
-			value := htmlquery.SelectAttr(o, "value")
-			// Check if the selected attr is set
-			if len(o.Attr) == 2 && o.Attr[1].Key == "selected" {
-				hostParams[selectName] = value
+			if hasAttr(o, "selected") {
+				hostParams[selectName] = cleanText(htmlquery.SelectAttr(o, "value"))
+				if selectName == "idview" {
+					hostParams["view"] = nodeText(o)
+				}
 				found = true
 				break
 			}
 		}
 
-		if selectName == "iddhcpprof" && !found {
-			hostParams[selectName] = 0
+		if selectName != "" && !found {
+			return nil, &NetmagisError{
+				msg:  fmt.Sprintf("no option selected for field '%s' of the host form", selectName),
+				kind: ErrValidation,
+			}
 		}
 	}
+	if hinfo, err := ParseHinfo(normalizeHostValue(hostParams["hinfo"])); err == nil {
+		hostParams["hinfo_pair"] = hinfo
+	}
+	parseTimestamps(form, hostParams)
 
 	return hostParams, nil
 }
 
+// Editable record of a name, with the addresses displayed on its form.
+type hostCandidate struct {
+	host      Host
+	addresses []string
+}
+
+// Report whether the record is selected by `selector` (see GetHost).
+func (candidate hostCandidate) matches(selector string) bool {
+	if ip := net.ParseIP(selector); ip != nil {
+		for _, address := range candidate.addresses {
+			if ip.Equal(net.ParseIP(address)) {
+				return true
+			}
+		}
+		return false
+	}
+	switch recordType := strings.ToUpper(selector); recordType {
+	case "A", "AAAA":
+		for _, address := range candidate.addresses {
+			if ip := net.ParseIP(address); ip != nil && (ip.To4() != nil) == (recordType == "A") {
+				return true
+			}
+		}
+		return false
+	}
+	view, _ := candidate.host["view"].(string)
+	return strings.EqualFold(view, selector)
+}
+
+// Return the IP addresses found in `text`.
+func findAddresses(text string) []string {
+	addresses := []string{}
+	for _, match := range ipAddressRegexp.FindAllString(text, -1) {
+		if net.ParseIP(match) != nil {
+			addresses = append(addresses, match)
+		}
+	}
+	return addresses
+}
+
+// Describe the records for an error message.
+func describeCandidates(candidates []hostCandidate) string {
+	descriptions := []string{}
+	for _, candidate := range candidates {
+		descriptions = append(descriptions, fmt.Sprintf(
+			"idrr %v (view: %v, addresses: %s)",
+			candidate.host["idrr"], candidate.host["view"], strings.Join(candidate.addresses, ", "),
+		))
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// Return `params` completed with the client default parameters (see
+// WithDefaultParams), without modifying it.
+func (c *NetmagisClient) withDefaultParams(params map[string]interface{}) map[string]interface{} {
+	if len(c.defaultParams) == 0 {
+		return params
+	}
+	merged := map[string]interface{}{}
+	for field, value := range c.defaultParams {
+		merged[field] = value
+	}
+	for field, value := range params {
+		merged[field] = value
+	}
+	return merged
+}
+
+// Add the host `fqdn` with the address `ip`. Besides the host fields, `params`
+// accepts `multiple` (allow adding an address to an existing name), `naddr` (number
+// of consecutive addresses to allocate from `ip`, 1 by default) and `confirm` (value
+// of the confirmation field, "yes" by default, for instances expecting another
+// token) and `force`.
+//
+// The address is submitted in its canonical form (see CanonicalIP); unspecified,
+// loopback and multicast addresses are refused unless `allow_special` is set.
+//
+// Netmagis may answer with an intermediate form before adding the host. Only a
+// confirmation restating the submitted host is submitted automatically; other
+// forms (warning prompts, choices, ...) fail with an error of kind ErrValidation
+// giving the page text. With `force`, they are all submitted with their current
+// values: the warnings are not reviewed, so this may e.g. declare a host with an
+// address or MAC address already in use.
+//
+// Parameters not given take the client defaults (see WithDefaultParams).
 func (c *NetmagisClient) AddHost(fqdn string, ip string, params map[string]interface{}) error {
-	name, domain := splitFqdn(fqdn)
+	fqdn = normalizeFqdn(fqdn)
+	params = c.withDefaultParams(params)
+	name, domain := c.splitFqdn(fqdn)
+	ip, err := CanonicalIP(ip, try(params, "allow_special", false).(bool))
+	if err != nil {
+		return err
+	}
+	if err := c.checkHostRules(fqdn); err != nil {
+		return err
+	}
 
 	// Check if host already exists
 	host, err := c.GetHost(fqdn)
 	if err != nil {
-		return &NetmagisError{fmt.Sprintf("unable to retrieve host: %s", err.Error())}
+		return &NetmagisError{msg: fmt.Sprintf("unable to retrieve host: %s", err.Error())}
 	}
 	if host != nil {
 		if !try(params, "multiple", false).(bool) {
 			return &NetmagisError{
-				fmt.Sprintf(
+				msg: fmt.Sprintf(
 					"host '%s' already declared, use `multiple` parameter to allow round-robin DNS",
 					fqdn,
 				),
@@ -420,19 +1677,32 @@ func (c *NetmagisClient) AddHost(fqdn string, ip string, params map[string]inter
 		}
 	}
 
+	hinfo, err := hinfoParam(try(params, "hinfo", "PC/Unix"))
+	if err != nil {
+		return err
+	}
+	// Round-robin names and several addresses are only managed through the forms
+	rest, err := c.useRESTWrites()
+	if err != nil {
+		return err
+	}
+	if naddr, _ := strToInt(try(params, "naddr", 1)); rest && host == nil && naddr <= 1 {
+		return c.restAddHost(fqdn, ip, hinfo, params)
+	}
+
 	// Format and send request
 	formData := url.Values{
 		"action":     {"add-host"},
-		"idview":     {"1"},
+		"idview":     {c.viewParam(params)},
 		"addr":       {ip},
 		"name":       {name},
 		"domain":     {domain},
-		"naddr":      {"1"},
-		"confirm":    {"yes"},
+		"naddr":      {intToStr(try(params, "naddr", 1))},
+		"confirm":    {try(params, "confirm", "yes").(string)},
 		"ttl":        {intToStr(try(params, "ttl", -1))},
 		"mac":        {try(params, "mac", "").(string)},
 		"iddhcpprof": {intToStr(try(params, "iddhcpprof", 0))},
-		"hinfo":      {try(params, "hinfo", "PC/Unix").(string)},
+		"hinfo":      {hinfo},
 		"comment":    {try(params, "comment", "").(string)},
 		"respname":   {try(params, "respname", "").(string)},
 		"respmail":   {try(params, "respmail", "").(string)},
@@ -441,31 +1711,42 @@ func (c *NetmagisClient) AddHost(fqdn string, ip string, params map[string]inter
 	if formData["sendsmtp"][0] == "0" {
 		delete(formData, "sendsmtp")
 	}
+	c.prepareForm(c.endpoints.Add, url.Values{}, formData)
 
-	checkFunc := func(body string) bool {
-		return strings.Contains(body, "Host has been added.")
-	}
+	checkFunc := confirmationCheck(c.markers.HostAdded)
+	verifyFunc := func() (bool, error) { return c.onPrimary().hostAdded(fqdn, ip) }
 
-	if _, err := c.Call("/add", formData, checkFunc); err != nil {
-		return err
-	}
-	return nil
+	return c.withForceSteps(try(params, "force", false).(bool)).
+		submit(c.endpoints.Add, formData, checkFunc, verifyFunc)
 }
 
 func (c *NetmagisClient) UpdateHost(fqdn string, idrr int, params map[string]interface{}) error {
-	name, domain := splitFqdn(fqdn)
+	fqdn = normalizeFqdn(fqdn)
+	params = c.withDefaultParams(params)
+	name, domain := c.splitFqdn(fqdn)
+	hinfo, err := hinfoParam(try(params, "hinfo", "PC/Unix"))
+	if err != nil {
+		return err
+	}
+	rest, err := c.useRESTWrites()
+	if err != nil {
+		return err
+	}
+	if rest {
+		return c.restUpdateHost(fqdn, idrr, hinfo, params)
+	}
 
 	formData := url.Values{
 		"action":     {"store"},
-		"confirm":    {"yes"},
+		"confirm":    {try(params, "confirm", "yes").(string)},
 		"idrr":       {strconv.Itoa(idrr)},
-		"idview":     {"1"},
+		"idview":     {c.viewParam(params)},
 		"name":       {name},
 		"domain":     {domain},
 		"ttl":        {intToStr(try(params, "ttl", ""))},
 		"mac":        {try(params, "mac", "").(string)},
 		"iddhcpprof": {intToStr(try(params, "iddhcpprof", 0))},
-		"hinfo":      {try(params, "hinfo", "PC/Unix").(string)},
+		"hinfo":      {hinfo},
 		"comment":    {try(params, "comment", "").(string)},
 		"respname":   {try(params, "respname", "").(string)},
 		"respmail":   {try(params, "respmail", "").(string)},
@@ -474,37 +1755,85 @@ func (c *NetmagisClient) UpdateHost(fqdn string, idrr int, params map[string]int
 	if formData["sendsmtp"][0] == "0" {
 		delete(formData, "sendsmtp")
 	}
+	editQuery := url.Values{"action": {"edit"}, "name": {name}, "domain": {domain}}
+	c.prepareForm(c.endpoints.Mod, editQuery, formData)
 
-	checkFunc := func(body string) bool {
-		return strings.Contains(body, "The modification has been stored in database")
-	}
+	checkFunc := confirmationCheck(c.markers.HostUpdated)
+	verifyFunc := func() (bool, error) { return c.onPrimary().hostUpdated(fqdn, params) }
 
-	if _, err := c.Call("/mod", formData, checkFunc); err != nil {
-		return err
-	}
-	return nil
+	return c.submit(c.endpoints.Mod, formData, checkFunc, verifyFunc)
 }
 
 func (c *NetmagisClient) DelHost(fqdn string) error {
-	name, domain := splitFqdn(fqdn)
+	return c.DelHostParams(fqdn, map[string]interface{}{})
+}
+
+// Same as DelHost with parameters:
+//   - check_aliases: refuse to delete the host while aliases still point to it
+//     (default: false)
+//   - safe: refuse to delete the host while other records depend on it (aliases,
+//     MX pointing to it, round-robin addresses), returning a *DependentsError
+//     (default: false)
+//   - force: delete the host even if `check_aliases` or `safe` would refuse it, and
+//     submit the intermediate forms Netmagis may answer with (e.g. a prompt
+//     warning that the aliases are removed too), which otherwise fail with an
+//     error of kind ErrValidation; dependent records (aliases, ...) may be left
+//     dangling or removed with the host (default: false)
+func (c *NetmagisClient) DelHostParams(fqdn string, params map[string]interface{}) error {
+	fqdn = normalizeFqdn(fqdn)
+	force := try(params, "force", false).(bool)
+	if !force && try(params, "safe", false).(bool) {
+		dependents, err := c.HostDependents(fqdn)
+		if err != nil {
+			return err
+		}
+		if dependents != nil {
+			return dependents
+		}
+	}
+	if !force && try(params, "check_aliases", false).(bool) {
+		aliases, err := c.ListAliasesFor(fqdn)
+		if err != nil {
+			return &NetmagisError{msg: fmt.Sprintf("unable to retrieve aliases: %s", err.Error())}
+		}
+		if len(aliases) > 0 {
+			return &NetmagisError{
+				msg: fmt.Sprintf(
+					"host '%s' is still referenced by aliases: %s",
+					fqdn, strings.Join(aliases, ", "),
+				),
+			}
+		}
+	}
+	rest, err := c.useRESTWrites()
+	if err != nil {
+		return err
+	}
+	if rest {
+		return c.restDelHost(fqdn, params)
+	}
+
+	name, domain := c.splitFqdn(fqdn)
 	formData := url.Values{
-		"idviews": {"1"},
+		"idviews": {c.viewParam(params)},
 		"name":    {name},
 		"domain":  {domain},
 	}
-	checkFunc := func(body string) bool {
-		return strings.Contains(body, "has been removed")
-	}
+	c.prepareForm(c.endpoints.Del, url.Values{}, formData)
 
-	if _, err := c.Call("/del", formData, checkFunc); err != nil {
-		return err
-	}
-	return nil
+	checkFunc := confirmationCheck(c.markers.HostRemoved)
+	verifyFunc := func() (bool, error) { return c.onPrimary().hostDeleted(fqdn) }
+
+	return c.withForceSteps(force).submit(c.endpoints.Del, formData, checkFunc, verifyFunc)
 }
 
 func (c *NetmagisClient) AddAlias(cname string, data string) error {
-	cnameName, cnameDomain := splitFqdn(cname)
-	dataName, dataDomain := splitFqdn(data)
+	cname, data = normalizeFqdn(cname), normalizeFqdn(data)
+	if err := c.checkAliasRules(cname, data); err != nil {
+		return err
+	}
+	cnameName, cnameDomain := c.splitFqdn(cname)
+	dataName, dataDomain := c.splitFqdn(data)
 
 	formData := url.Values{
 		"action":    {"add-alias"},
@@ -512,14 +1841,200 @@ func (c *NetmagisClient) AddAlias(cname string, data string) error {
 		"domain":    {cnameDomain},
 		"nameref":   {dataName},
 		"domainref": {dataDomain},
-		"idview":    {"1"},
+		"idview":    {c.DefaultView()},
 	}
-	checkFunc := func(body string) bool {
-		return strings.Contains(body, "The alias has been added")
+	c.prepareForm(c.endpoints.Add, url.Values{}, formData)
+
+	checkFunc := confirmationCheck(c.markers.AliasAdded)
+	verifyFunc := func() (bool, error) { return c.onPrimary().aliasAdded(cname, data) }
+
+	return c.submit(c.endpoints.Add, formData, checkFunc, verifyFunc)
+}
+
+// Return the FQDN targeted by the alias `cname`, or an empty string when the alias
+// does not exist.
+func (c *NetmagisClient) GetAlias(cname string) (string, error) {
+	host, err := c.Search(cname)
+	if err != nil || host == nil {
+		return "", err
+	}
+	if !host["is_alias"].(bool) {
+		return "", &NetmagisError{msg: fmt.Sprintf("'%s' is not an alias", cname)}
+	}
+	return host["name"].(string), nil
+}
+
+// List the aliases pointing to the host `fqdn`.
+func (c *NetmagisClient) ListAliasesFor(fqdn string) ([]string, error) {
+	host, err := c.Search(fqdn)
+	if err != nil || host == nil {
+		return []string{}, err
+	}
+	if host["is_alias"].(bool) {
+		return nil, &NetmagisError{msg: fmt.Sprintf("'%s' is an alias", fqdn)}
+	}
+
+	return searchValues(host, "aliases"), nil
+}
+
+// Records depending on a host, returned by DelHostParams when refusing to delete it.
+type DependentsError struct {
+	Host string
+	// Aliases pointing to the host
+	Aliases []string
+	// Names using the host as mail relay (MX)
+	MailRelayFor []string
+	// Addresses of the name (more than one for round-robin DNS)
+	Addresses []string
+}
+
+func (error *DependentsError) Error() string {
+	dependents := []string{}
+	if len(error.Aliases) > 0 {
+		dependents = append(dependents, "aliases: "+strings.Join(error.Aliases, ", "))
+	}
+	if len(error.MailRelayFor) > 0 {
+		dependents = append(dependents, "MX for: "+strings.Join(error.MailRelayFor, ", "))
+	}
+	if len(error.Addresses) > 1 {
+		dependents = append(dependents, "addresses: "+strings.Join(error.Addresses, ", "))
+	}
+	return fmt.Sprintf(
+		"host '%s' has dependent records (%s)", error.Host, strings.Join(dependents, "; "),
+	)
+}
+
+// Return the values of a multi-valued search field.
+func searchValues(host Host, fields ...string) []string {
+	values := []string{}
+	for _, field := range fields {
+		switch value := host[field].(type) {
+		case []string:
+			values = append(values, value...)
+		case string:
+			values = append(values, splitValues(value)...)
+		}
+	}
+
+	result := []string{}
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// Return the records depending on the host `fqdn`, or nil when there is none.
+func (c *NetmagisClient) HostDependents(fqdn string) (*DependentsError, error) {
+	host, err := c.Search(fqdn)
+	if err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("unable to retrieve host: %s", err.Error())}
+	}
+	if host == nil || host["is_alias"].(bool) {
+		return nil, nil
+	}
+
+	dependents := &DependentsError{
+		Host:         fqdn,
+		Aliases:      searchValues(host, "aliases"),
+		MailRelayFor: searchValues(host, "mail_relay_for", "mx_for"),
+		Addresses:    searchValues(host, "ip_addresses", "ip_address"),
+	}
+	if len(dependents.Aliases) == 0 &&
+		len(dependents.MailRelayFor) == 0 &&
+		len(dependents.Addresses) <= 1 {
+		return nil, nil
+	}
+	return dependents, nil
+}
+
+// Action taken by UpsertAlias.
+type AliasAction string
+
+const (
+	AliasUnchanged AliasAction = "unchanged"
+	AliasCreated   AliasAction = "created"
+	AliasUpdated   AliasAction = "updated"
+)
+
+// Make the alias `cname` point to `data`: the alias is created when absent,
+// repointed (removed then re-added) when targeting another name, and left
+// untouched when already pointing to `data`.
+func (c *NetmagisClient) UpsertAlias(cname string, data string) (AliasAction, error) {
+	cname, data = normalizeFqdn(cname), normalizeFqdn(data)
+	current, err := c.GetAlias(cname)
+	if err != nil {
+		return "", err
+	}
+
+	switch normalizeFqdn(current) {
+	case data:
+		return AliasUnchanged, nil
+	case "":
+		if err := c.AddAlias(cname, data); err != nil {
+			return "", err
+		}
+		return AliasCreated, nil
+	}
+
+	// Checked before removing the current alias (see WithStrictDNS)
+	if err := c.checkTargetRules("CNAME", data); err != nil {
+		return "", err
+	}
+	if err := c.DelHost(cname); err != nil {
+		return "", &NetmagisError{
+			msg: fmt.Sprintf("unable to remove alias '%s': %s", cname, err.Error()),
+		}
+	}
+	if err := c.AddAlias(cname, data); err != nil {
+		return "", &NetmagisError{
+			msg: fmt.Sprintf("alias '%s' removed but not re-added: %s", cname, err.Error()),
+		}
+	}
+	return AliasUpdated, nil
+}
+
+// Maximum length of a host comment.
+const maxCommentLength = 255
+
+// Update only the given fields of the host `fqdn`, the other fields being preserved
+// from its current state (see GetHost).
+func (c *NetmagisClient) updateHostFields(fqdn string, fields map[string]interface{}) error {
+	host, err := c.GetHost(fqdn)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("unable to retrieve host: %s", err.Error()), err: err}
+	}
+	if host == nil {
+		return &NetmagisError{msg: fmt.Sprintf("host '%s' does not exist", fqdn), kind: ErrNotFound}
 	}
 
-	if _, err := c.Call("/add", formData, checkFunc); err != nil {
+	idrr, err := hostIdrr(host)
+	if err != nil {
 		return err
 	}
-	return nil
+	for field, value := range fields {
+		host[field] = value
+	}
+	return c.UpdateHost(fqdn, idrr, host)
+}
+
+// Set the comment of a host (an empty comment clears it), preserving its other
+// fields.
+func (c *NetmagisClient) SetComment(fqdn string, comment string) error {
+	if length := utf8.RuneCountInString(comment); length > maxCommentLength {
+		return &NetmagisError{
+			msg: fmt.Sprintf("comment too long (%d > %d characters)", length, maxCommentLength),
+		}
+	}
+	return c.updateHostFields(fqdn, map[string]interface{}{"comment": comment})
+}
+
+// Set the responsible person (name and mail) of a host, preserving its other
+// fields. Empty values clear them.
+func (c *NetmagisClient) SetResponsible(fqdn string, name string, mail string) error {
+	if mail != "" && !checkMail(mail) {
+		return &NetmagisError{msg: fmt.Sprintf("invalid responsible mail '%s'", mail)}
+	}
+	return c.updateHostFields(fqdn, map[string]interface{}{"respname": name, "respmail": mail})
 }
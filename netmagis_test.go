@@ -0,0 +1,328 @@
+package netmagis
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Return the content of the fixture `name` of the testdata directory.
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("unable to read fixture: %s", err)
+	}
+	return string(content)
+}
+
+// Return a client (not authenticated) bound to a server answering with `handler`.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...ClientOption) *NetmagisClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := newClient(server.URL, opts)
+	if err != nil {
+		t.Fatalf("unable to initialize client: %s", err)
+	}
+	return client
+}
+
+// Return a handler serving the fixtures by path (e.g. `/search` to the content of
+// the fixture `pages["/search"]`).
+func fixtureHandler(t *testing.T, pages map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, found := pages[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(fixture(t, name)))
+	}
+}
+
+func TestSearchAllMultipleMatches(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": "search_multiple.html"}))
+
+	hosts, err := client.SearchAll("192.0.2.10")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 matches, got %d: %v", len(hosts), hosts)
+	}
+	for idx, expected := range []struct{ name, mac string }{
+		{"web1.example.com", "00:11:22:33:44:55"},
+		{"web2.example.com", "00:11:22:33:44:66"},
+	} {
+		if hosts[idx]["name"] != expected.name || hosts[idx]["mac"] != expected.mac {
+			t.Errorf("match %d: expected %s (%s), got %v", idx, expected.name, expected.mac, hosts[idx])
+		}
+		if hosts[idx]["view"] != "default" {
+			t.Errorf("match %d: expected view default, got %v", idx, hosts[idx]["view"])
+		}
+	}
+}
+
+func TestSearchRecordTypes(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		query      string
+		recordType RecordType
+		isAlias    bool
+		view       string
+	}{
+		{"search_host.html", "www.example.com", RecordTypeHost, false, "default"},
+		{"search_alias.html", "web.example.com", RecordTypeAlias, true, "default"},
+		{"search_ptr.html", "192.0.2.1", RecordTypePtr, false, "internal"},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": test.fixture}))
+			host, err := client.Search(test.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if host["record_type"] != test.recordType {
+				t.Errorf("expected record type %s, got %v", test.recordType, host["record_type"])
+			}
+			if host["is_alias"] != test.isAlias {
+				t.Errorf("expected is_alias %t, got %v", test.isAlias, host["is_alias"])
+			}
+			if host["view"] != test.view {
+				t.Errorf("expected view %s, got %v", test.view, host["view"])
+			}
+			// The name is the one of the host, also for aliases and reverse entries
+			if host["name"] != "www.example.com" {
+				t.Errorf("expected name www.example.com, got %v", host["name"])
+			}
+		})
+	}
+}
+
+func TestParseRecordType(t *testing.T) {
+	tests := map[string]RecordType{
+		"host":       RecordTypeHost,
+		"alias":      RecordTypeAlias,
+		"IP address": RecordTypePtr,
+		"reverse":    RecordTypePtr,
+		"mail relay": RecordTypeUnknown,
+	}
+	for description, expected := range tests {
+		if recordType := parseRecordType(description); recordType != expected {
+			t.Errorf("%s: expected %s, got %s", description, expected, recordType)
+		}
+	}
+}
+
+func TestNormalizeLabel(t *testing.T) {
+	tests := map[string]string{
+		"Name":                  "name",
+		"IP address(es)":        "ip_addresses",
+		"Name :":                "name",
+		"SMTP emit right:":      "smtp_emit_right",
+		"DHCP\u00a0profile":     "dhcp_profile",
+		"DHCP&nbsp;profile":     "dhcp_profile",
+		"  SMTP\n  emit  right": "smtp_emit_right",
+	}
+	for label, expected := range tests {
+		if key := normalizeLabel(label); key != expected {
+			t.Errorf("%q: expected %q, got %q", label, expected, key)
+		}
+	}
+}
+
+func TestSearchLabels(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": "search_labels.html"}))
+	host, err := client.Search("www.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := Host{
+		"name":            "www.example.com",
+		"ip_addresses":    "192.0.2.1",
+		"smtp_emit_right": true,
+		"dhcp_profile":    "",
+	}
+	for field, value := range expected {
+		if host[field] != value {
+			t.Errorf("%s: expected %v, got %v", field, value, host[field])
+		}
+	}
+}
+
+func TestSearchLists(t *testing.T) {
+	aliases := []string{"web.example.com", "www2.example.com", "ftp.example.com"}
+	groups := []string{"admins", "net-team"}
+	for _, name := range []string{"search_lists_comma.html", "search_lists_lines.html"} {
+		client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": name}))
+		host, err := client.Search("www.example.com")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if !reflect.DeepEqual(host["aliases"], aliases) {
+			t.Errorf("%s: expected aliases %v, got %v", name, aliases, host["aliases"])
+		}
+		if !reflect.DeepEqual(host["allowed_groups"], groups) {
+			t.Errorf("%s: expected groups %v, got %v", name, groups, host["allowed_groups"])
+		}
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := map[string]string{
+		"  plain  ":   "plain",
+		"caf&eacute;": "café",
+		"R&amp;D":     "R&D",
+		"&lt;lab&gt;": "<lab>",
+	}
+	for text, expected := range tests {
+		if value := cleanText(text); value != expected {
+			t.Errorf("%q: expected %q, got %q", text, expected, value)
+		}
+	}
+}
+
+func TestEntitiesDecoded(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{
+		"/search": "search_entities.html",
+		"/mod":    "mod_host.html",
+	}))
+
+	host, err := client.Search("www.example.com")
+	if err != nil {
+		t.Fatalf("Search: unexpected error: %s", err)
+	}
+	form, err := client.GetHost("www.example.com")
+	if err != nil {
+		t.Fatalf("GetHost: unexpected error: %s", err)
+	}
+	for _, result := range []struct {
+		method  string
+		host    Host
+		nameKey string
+	}{
+		{"Search", host, "responsible_name"},
+		{"GetHost", form, "respname"},
+	} {
+		if comment := result.host["comment"]; comment != "R&D café <lab>" {
+			t.Errorf("%s: expected decoded comment, got %q", result.method, comment)
+		}
+		if name := result.host[result.nameKey]; name != "Jérôme" {
+			t.Errorf("%s: expected decoded responsible, got %q", result.method, name)
+		}
+	}
+}
+
+func TestCheckFormValues(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"Caf\u00e9 du r\u00e9seau", ""},
+		{"Caf\u00e9\r\nX-Injected: 1", `control character '\r' at position 4`},
+		{"\u00e9t\u00e9\x00", `control character '\x00' at position 3`},
+	}
+	for _, test := range tests {
+		err := checkFormValues(url.Values{"comment": {test.value}})
+		switch {
+		case test.expected == "" && err != nil:
+			t.Errorf("%q: unexpected error: %s", test.value, err)
+		case test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)):
+			t.Errorf("%q: expected error with %q, got %v", test.value, test.expected, err)
+		}
+	}
+}
+
+func TestNormalizeFqdn(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com":  "www.example.com",
+		"www.example.com.": "www.example.com",
+		"WWW.Example.COM.": "www.example.com",
+		"localhost":        "localhost",
+	}
+	for fqdn, expected := range tests {
+		if value := normalizeFqdn(fqdn); value != expected {
+			t.Errorf("%q: expected %q, got %q", fqdn, expected, value)
+		}
+	}
+}
+
+func TestSplitFqdnZone(t *testing.T) {
+	zones := []string{"example.com", "lab.example.com."}
+	tests := []struct {
+		fqdn, name, domain string
+	}{
+		{"www.example.com", "www", "example.com"},
+		{"www.example.com.", "www", "example.com"},
+		{"host.lab.example.com", "host", "lab.example.com"},
+		{"host.lab.example.com.", "host", "lab.example.com"},
+		{"a.b.example.com.", "a.b", "example.com"},
+		{"www.example.org.", "www", "example.org"},
+	}
+	for _, test := range tests {
+		name, domain := splitFqdnZone(test.fqdn, zones)
+		if name != test.name || domain != test.domain {
+			t.Errorf("%q: expected (%q, %q), got (%q, %q)", test.fqdn, test.name, test.domain, name, domain)
+		}
+	}
+}
+
+func TestCheckFqdn(t *testing.T) {
+	tests := map[string]bool{
+		"www.example.com":   true,
+		"www.example.com.":  true,
+		"WWW.EXAMPLE.COM.":  true,
+		"www..example.com":  false,
+		"www.example.com..": false,
+		"":                  false,
+		".":                 false,
+	}
+	for fqdn, expected := range tests {
+		if valid := checkFqdn(fqdn); valid != expected {
+			t.Errorf("%q: expected %t, got %t", fqdn, expected, valid)
+		}
+	}
+}
+
+func TestCanonicalIP(t *testing.T) {
+	tests := []struct {
+		ip           string
+		allowSpecial bool
+		expected     string
+		err          string
+	}{
+		{"192.0.2.1", false, "192.0.2.1", ""},
+		{" 192.0.2.1 ", false, "192.0.2.1", ""},
+		{"::ffff:1.2.3.4", false, "1.2.3.4", ""},
+		{"2001:0DB8:0:0:0:0:0:1", false, "2001:db8::1", ""},
+		{"2001:db8:0:0:1:0:0:1", false, "2001:db8::1:0:0:1", ""},
+		{"127.0.0.1", false, "", "loopback"},
+		{"::1", false, "", "loopback"},
+		{"224.0.0.1", false, "", "multicast"},
+		{"ff02::1", false, "", "multicast"},
+		{"0.0.0.0", false, "", "unspecified"},
+		{"::", false, "", "unspecified"},
+		{"127.0.0.1", true, "127.0.0.1", ""},
+		{"ff02::1", true, "ff02::1", ""},
+		{"::", true, "::", ""},
+		{"192.0.2.256", false, "", "invalid"},
+		{"www.example.com", true, "", "invalid"},
+	}
+	for _, test := range tests {
+		ip, err := CanonicalIP(test.ip, test.allowSpecial)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %s", test.ip, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%q: expected error with %q, got %v", test.ip, test.err, err)
+		case ip != test.expected:
+			t.Errorf("%q: expected %q, got %q", test.ip, test.expected, ip)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package netmagis
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var networkOptionRegexp = regexp.MustCompile(`^\s*([0-9a-fA-F.:]+/[0-9]+)\s*(.*)$`)
+
+// Network managed by Netmagis.
+type Network struct {
+	Id   int
+	Cidr string
+	Name string
+}
+
+// List the networks the user is allowed to consult, from the network select of the
+// /net form.
+func (c *NetmagisClient) ListNetworks() ([]Network, error) {
+	body, err := c.Call(c.endpoints.Net, url.Values{}, func(body string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse /net HTML response: %s", err.Error()),
+		}
+	}
+
+	networks := []Network{}
+	for _, node := range htmlquery.Find(doc, "//select[@name='plages']/option") {
+		id, err := strconv.Atoi(htmlquery.SelectAttr(node, "value"))
+		if err != nil {
+			continue
+		}
+		submatch := networkOptionRegexp.FindStringSubmatch(nodeText(node))
+		if submatch == nil {
+			continue
+		}
+		networks = append(networks, Network{
+			Id:   id,
+			Cidr: submatch[1],
+			Name: strings.TrimSpace(submatch[2]),
+		})
+	}
+	return networks, nil
+}
+
+// Select the networks matching the given CIDRs (all networks when no CIDR is given).
+func (c *NetmagisClient) selectNetworks(cidrs []string) ([]Network, error) {
+	networks, err := c.ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+	if len(cidrs) == 0 {
+		return networks, nil
+	}
+
+	selected := []Network{}
+	for _, cidr := range cidrs {
+		found := false
+		for _, network := range networks {
+			if network.Cidr == cidr {
+				selected = append(selected, network)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &NetmagisError{msg: fmt.Sprintf("unknown network '%s'", cidr)}
+		}
+	}
+	return selected, nil
+}
+
+// Maximum number of pages crawled for the hosts of a network.
+const maxNetworkPages = 100
+
+// Retrieve the hosts of a network from its consultation page, following the pages
+// of the listing when it is paginated. The hosts of each page are given to `yield`
+// as soon as the page is parsed; an error of `yield` stops the listing.
+func (c *NetmagisClient) listNetworkHosts(network Network, yield func(hosts []Host) error) error {
+	query := url.Values{"action": {"consult"}, "plages": {strconv.Itoa(network.Id)}}
+	for page := 0; page < maxNetworkPages; page++ {
+		body, err := c.Call(c.endpoints.Net, query, func(body string) bool { return true })
+		if err != nil {
+			return err
+		}
+
+		doc, err := htmlquery.Parse(strings.NewReader(body))
+		if err != nil {
+			return &NetmagisError{
+				msg: fmt.Sprintf("unable to parse /net HTML response: %s", err.Error()),
+			}
+		}
+
+		hosts := []Host{}
+		for _, table := range htmlquery.Find(doc, "//table[@class='tab-text10']") {
+			for _, row := range parseTable(table) {
+				if row["name"] == "" {
+					continue
+				}
+				host := Host{"network": network.Cidr}
+				for field, value := range row {
+					host[field] = value
+				}
+				host["reserved"] = isReservation(row["comment"])
+				hosts = append(hosts, host)
+			}
+		}
+		if err := yield(hosts); err != nil {
+			return err
+		}
+
+		next, found := nextPageQuery(doc)
+		if !found {
+			break
+		}
+		query = next
+	}
+	return nil
+}
+
+// List the hosts declared in the given networks (CIDRs), or in all the networks
+// the user is allowed to consult when none is given. Addresses reserved with
+// ReserveIP have their `reserved` field set.
+func (c *NetmagisClient) ListHosts(networks ...string) ([]Host, error) {
+	hosts := []Host{}
+	hostsChan, errChan := c.ListHostsIter(c.context(), networks...)
+	for host := range hostsChan {
+		hosts = append(hosts, host)
+	}
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// Same as ListHosts but hosts are sent on the returned channel as the pages of the
+// networks are crawled. The hosts channel is closed when the crawl ends, then the
+// error channel yields the error that interrupted it (if any) and is closed.
+// Cancelling `ctx` stops the crawl; a consumer stopping to read before the end
+// must cancel it, otherwise the crawl stays blocked on the hosts channel.
+func (c *NetmagisClient) ListHostsIter(ctx context.Context, networks ...string) (<-chan Host, <-chan error) {
+	hostsChan := make(chan Host)
+	errChan := make(chan error, 1)
+	client := c.WithContext(ctx)
+
+	go func() {
+		defer close(errChan)
+		defer close(hostsChan)
+
+		selected, err := client.selectNetworks(networks)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		send := func(hosts []Host) error {
+			for _, host := range hosts {
+				select {
+				case hostsChan <- host:
+				case <-ctx.Done():
+					return &NetmagisError{
+						msg: fmt.Sprintf("hosts listing interrupted: %s", ctx.Err().Error()),
+						err: ctx.Err(),
+					}
+				}
+			}
+			return nil
+		}
+		for _, network := range selected {
+			if err := client.listNetworkHosts(network, send); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return hostsChan, errChan
+}
+
+// Maximum number of addresses tried by AddHostAuto.
+const maxAutoAddAttempts = 5
+
+var addressTakenRegexp = regexp.MustCompile(`(?i)address[^.]*(already|is used)`)
+
+// Return the address of a host listed in a network.
+func hostAddress(host Host) string {
+	for _, field := range []string{"ip_address", "address", "ip"} {
+		if address, ok := host[field].(string); ok && address != "" {
+			return address
+		}
+	}
+	return ""
+}
+
+// Return the free IPv4 addresses of the network `cidr` (network and broadcast
+// addresses excluded), in ascending order.
+func (c *NetmagisClient) FindFreeIPs(cidr string) ([]string, error) {
+	return c.findFreeIPs(cidr, 0)
+}
+
+// Return at most `count` free IPv4 addresses of the network `cidr` (all of them
+// when `count` is 0), in ascending order. The range is walked lazily so that
+// the lookup stops as soon as enough addresses are found.
+func (c *NetmagisClient) findFreeIPs(cidr string, count int) ([]string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("invalid network '%s': %s", cidr, err.Error())}
+	}
+	ones, bits := network.Mask.Size()
+	if bits != 32 {
+		return nil, &NetmagisError{msg: "free addresses lookup is only supported for IPv4 networks"}
+	}
+
+	hosts, err := c.ListHosts(cidr)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, host := range hosts {
+		used[hostAddress(host)] = true
+	}
+
+	free := []string{}
+	first := binary.BigEndian.Uint32(network.IP.To4())
+	last := first | ^binary.BigEndian.Uint32(net.IP(network.Mask).To4())
+	if ones < 31 {
+		first, last = first+1, last-1
+	}
+	for value := uint64(first); value <= uint64(last); value++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(value))
+		if used[ip.String()] {
+			continue
+		}
+		free = append(free, ip.String())
+		if count > 0 && len(free) >= count {
+			break
+		}
+	}
+	return free, nil
+}
+
+// Return the first free IPv4 address of the network `cidr`.
+func (c *NetmagisClient) FindFreeIP(cidr string) (string, error) {
+	free, err := c.findFreeIPs(cidr, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(free) == 0 {
+		return "", &NetmagisError{msg: fmt.Sprintf("no free address in network '%s'", cidr)}
+	}
+	return free[0], nil
+}
+
+// Create the host `fqdn` on a free address of the network `cidr` (see AddHost for
+// `params`) and return the assigned address. If the address gets taken between its
+// discovery and the host creation, the next free address is tried.
+func (c *NetmagisClient) AddHostAuto(fqdn string, cidr string, params map[string]interface{}) (string, error) {
+	free, err := c.findFreeIPs(cidr, maxAutoAddAttempts)
+	if err != nil {
+		return "", err
+	}
+	if len(free) == 0 {
+		return "", &NetmagisError{msg: fmt.Sprintf("no free address in network '%s'", cidr)}
+	}
+
+	for _, ip := range free {
+		err := c.AddHost(fqdn, ip, params)
+		if err == nil {
+			return ip, nil
+		}
+		if !addressTakenRegexp.MatchString(err.Error()) {
+			return "", err
+		}
+	}
+	return "", &NetmagisError{msg: fmt.Sprintf("no free address in network '%s'", cidr)}
+}
+
+// Return the managed network containing the address `ip` (the most specific one
+// when networks overlap), or an empty network when it is not in a network the user
+// is allowed to consult.
+func (c *NetmagisClient) NetworkForIP(ip string) (Network, error) {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return Network{}, &NetmagisError{msg: fmt.Sprintf("invalid IP address '%s'", ip)}
+	}
+	networks, err := c.ListNetworks()
+	if err != nil {
+		return Network{}, err
+	}
+	return networkContaining(networks, parsedIp), nil
+}
+
+// Return the managed networks of the addresses of the host `fqdn`, in the order of
+// its addresses. Addresses outside of the networks the user is allowed to consult
+// are skipped, so the result is empty when no network is determinable.
+func (c *NetmagisClient) HostNetworks(fqdn string) ([]Network, error) {
+	host, err := c.Search(fqdn)
+	if err != nil || host == nil {
+		return []Network{}, err
+	}
+	addresses := searchValues(host, "ip_addresses", "ip_address")
+	if len(addresses) == 0 {
+		return []Network{}, nil
+	}
+	networks, err := c.ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	hostNetworks := []Network{}
+	seen := map[int]bool{}
+	for _, address := range addresses {
+		network := networkContaining(networks, net.ParseIP(address))
+		if network.Cidr != "" && !seen[network.Id] {
+			seen[network.Id] = true
+			hostNetworks = append(hostNetworks, network)
+		}
+	}
+	return hostNetworks, nil
+}
+
+// Return the most specific network of `networks` containing `ip` (an empty network
+// when there is none).
+func networkContaining(networks []Network, ip net.IP) Network {
+	found, foundSize := Network{}, -1
+	if ip == nil {
+		return found
+	}
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.Cidr)
+		if err != nil || !ipNet.Contains(ip) {
+			continue
+		}
+		if size, _ := ipNet.Mask.Size(); size > foundSize {
+			found, foundSize = network, size
+		}
+	}
+	return found
+}
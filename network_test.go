@@ -0,0 +1,112 @@
+package netmagis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// Return a handler of /net serving the networks list, and the hosts of the
+// network 10.0.0.0/8 on consultation.
+func netHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("action") == "consult" {
+			w.Write([]byte(fixture(t, "net_consult_campus.html")))
+			return
+		}
+		w.Write([]byte(fixture(t, "net_list.html")))
+	}
+}
+
+func TestFindFreeIP(t *testing.T) {
+	client := newTestClient(t, netHandler(t))
+
+	ip, err := client.FindFreeIP("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ip != "10.0.0.3" {
+		t.Errorf("expected 10.0.0.3, got %s", ip)
+	}
+}
+
+func TestFindFreeIPsCount(t *testing.T) {
+	client := newTestClient(t, netHandler(t))
+
+	free, err := client.findFreeIPs("10.0.0.0/8", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"10.0.0.3", "10.0.0.5", "10.0.0.6"}
+	if len(free) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, free)
+	}
+	for idx := range expected {
+		if free[idx] != expected[idx] {
+			t.Errorf("expected %v, got %v", expected, free)
+			break
+		}
+	}
+}
+
+// Return a handler of /net serving a two-page listing of the network 10.0.0.0/8,
+// counting the pages served in `pages`.
+func pagedNetHandler(t *testing.T, pages *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.Form.Get("action") != "consult":
+			w.Write([]byte(fixture(t, "net_list.html")))
+		case r.Form.Get("page") == "2":
+			atomic.AddInt32(pages, 1)
+			w.Write([]byte(fixture(t, "net_consult_page2.html")))
+		default:
+			atomic.AddInt32(pages, 1)
+			w.Write([]byte(fixture(t, "net_consult_page1.html")))
+		}
+	}
+}
+
+func TestListHostsPages(t *testing.T) {
+	pages := int32(0)
+	client := newTestClient(t, pagedNetHandler(t, &pages))
+
+	hosts, err := client.ListHosts("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(hosts) != 3 || pages != 2 {
+		t.Errorf("expected 3 hosts on 2 pages, got %d hosts on %d pages", len(hosts), pages)
+	}
+}
+
+func TestListHostsIterEarlyTermination(t *testing.T) {
+	pages := int32(0)
+	client := newTestClient(t, pagedNetHandler(t, &pages))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hostsChan, errChan := client.ListHostsIter(ctx, "10.0.0.0/8")
+	host := <-hostsChan
+	if host["name"] != "gw.example.com" {
+		t.Fatalf("expected the first host of the first page, got %v", host)
+	}
+	// The first host is received before the next page is requested
+	if requested := atomic.LoadInt32(&pages); requested != 1 {
+		t.Errorf("expected 1 page requested, got %d", requested)
+	}
+
+	// The consumer stops reading: the crawl ends once the context is cancelled
+	cancel()
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the listing to be cancelled, got %v", err)
+	}
+	if _, open := <-hostsChan; open {
+		t.Error("expected the hosts channel to be closed")
+	}
+	if requested := atomic.LoadInt32(&pages); requested != 1 {
+		t.Errorf("expected the listing to stop on the first page, got %d pages", requested)
+	}
+}
@@ -0,0 +1,377 @@
+package netmagis
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Paths of the Netmagis endpoints, relative to the base URL.
+type Endpoints struct {
+	Start   string
+	Index   string
+	Search  string
+	Add     string
+	Mod     string
+	Del     string
+	Net     string
+	Profile string
+	AdmRef  string
+	Recent  string
+}
+
+// Endpoints of a standard Netmagis installation.
+var DefaultEndpoints = Endpoints{
+	Start:   "/start",
+	Index:   "/index",
+	Search:  "/search",
+	Add:     "/add",
+	Mod:     "/mod",
+	Del:     "/del",
+	Net:     "/net",
+	Profile: "/profile",
+	AdmRef:  "/admref",
+	Recent:  "/lasthosts",
+}
+
+// Option configuring a NetmagisClient at creation time (see NewClient).
+type ClientOption func(*NetmagisClient)
+
+// Use an existing cookie jar instead of creating a new one. This allows sharing
+// an authenticated session between several clients pointed at the same server.
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *NetmagisClient) {
+		c.jar = jar
+	}
+}
+
+// Set the zones managed by Netmagis, used for splitting FQDNs into name and domain
+// (e.g. `host.sub.example.com` is split into `host.sub` and `example.com` when
+// `example.com` is a managed zone). Without zones, FQDNs are split on the first
+// dot. See also NetmagisClient.ResolveZones.
+func WithZones(zones ...string) ClientOption {
+	return func(c *NetmagisClient) {
+		c.zones = zones
+	}
+}
+
+// Bind all the operations of the client, including the CAS authentication, to
+// `ctx`. A context given per call with NetmagisClient.WithContext takes
+// precedence over this one.
+func WithBaseContext(ctx context.Context) ClientOption {
+	return func(c *NetmagisClient) {
+		c.ctx = ctx
+	}
+}
+
+// Add static headers to every request (e.g. for reverse proxies requiring an
+// authentication token). Use RedactHeaders before logging them.
+func WithHTTPHeaders(headers http.Header) ClientOption {
+	return func(c *NetmagisClient) {
+		c.headers = headers.Clone()
+	}
+}
+
+// Set the empty string fields of the struct pointed to by `dst` to the value of
+// the same field of `defaults`, a struct of the same type.
+func fillDefaults(dst interface{}, defaults interface{}) {
+	overrides := reflect.ValueOf(dst).Elem()
+	defaultValues := reflect.ValueOf(defaults)
+	for idx := 0; idx < overrides.NumField(); idx++ {
+		if overrides.Field(idx).String() == "" {
+			overrides.Field(idx).Set(defaultValues.Field(idx))
+		}
+	}
+}
+
+// Override the paths of the Netmagis endpoints, for installations with non-standard
+// mount points. Empty paths keep their default value (see DefaultEndpoints).
+func WithEndpoints(endpoints Endpoints) ClientOption {
+	return func(c *NetmagisClient) {
+		fillDefaults(&endpoints, DefaultEndpoints)
+		c.endpoints = endpoints
+	}
+}
+
+// Override the success messages of Netmagis, for localized or customized
+// installations. Empty messages keep their default value (see DefaultMarkers).
+func WithMarkers(markers Markers) ClientOption {
+	return func(c *NetmagisClient) {
+		fillDefaults(&markers, DefaultMarkers)
+		c.markers = markers
+	}
+}
+
+// Scope the mutating operations to the organization `organization` (see
+// ListOrganizations), for multi-tenant instances. The organization is submitted
+// with the forms declaring an `org` field; stock Netmagis forms have none, in
+// which case it has no effect. NetmagisClient.ForOrganization gives the per-call
+// counterpart.
+func WithOrganization(organization string) ClientOption {
+	return func(c *NetmagisClient) {
+		c.organization = organization
+	}
+}
+
+// Write each raw Netmagis response to `dir` (named after the timestamp and the
+// endpoint), for collecting fixtures or debugging parsing failures. Responses may
+// contain sensitive data, so this must only be enabled explicitly.
+func WithDebugDump(dir string) ClientOption {
+	return func(c *NetmagisClient) {
+		c.debugDir = dir
+	}
+}
+
+// Use `config` for TLS connections to Netmagis (e.g. for trusting a private CA).
+// Unless WithCASTLSConfig is also given, it is used for connections to CAS too.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *NetmagisClient) {
+		c.tlsConfig = config
+	}
+}
+
+// Use `config` for TLS connections to CAS (i.e. any host other than the Netmagis
+// one), for environments where CAS is signed by a different authority than
+// Netmagis. Alternatively, a single configuration whose RootCAs pool contains both
+// authorities can be given with WithTLSConfig.
+func WithCASTLSConfig(config *tls.Config) ClientOption {
+	return func(c *NetmagisClient) {
+		c.casTLSConfig = config
+	}
+}
+
+// Override the CAS `service` parameter of the login URL retrieved from the start
+// page, for federated setups where the redirect does not carry the right service.
+// The auto-detected service is used when not given.
+func WithCASService(service string) ClientOption {
+	return func(c *NetmagisClient) {
+		c.casService = service
+	}
+}
+
+// Follow at most `max` redirects after posting the CAS credentials, for setups
+// bouncing through several hops before landing on Netmagis (10 by default).
+func WithCASMaxRedirects(max int) ClientOption {
+	return func(c *NetmagisClient) {
+		c.casMaxRedirects = max
+	}
+}
+
+// Report Netmagis error messages matching one of `patterns` as ErrQuotaExceeded
+// instead of DefaultQuotaPatterns, e.g. for localized instances.
+func WithQuotaPatterns(patterns ...*regexp.Regexp) ClientOption {
+	return func(c *NetmagisClient) {
+		c.quotaPatterns = patterns
+	}
+}
+
+// Detect unknown names with `patterns` instead of DefaultNotFoundPatterns, both on
+// the search page (see Search) and in error messages (reported as ErrNotFound),
+// e.g. for localized instances.
+func WithNotFoundPatterns(patterns ...*regexp.Regexp) ClientOption {
+	return func(c *NetmagisClient) {
+		c.notFoundPatterns = patterns
+	}
+}
+
+// Report Netmagis error messages matching one of `patterns` as ErrPermissionDenied
+// instead of DefaultPermissionPatterns, e.g. for localized instances.
+func WithPermissionPatterns(patterns ...*regexp.Regexp) ClientOption {
+	return func(c *NetmagisClient) {
+		c.permissionPatterns = patterns
+	}
+}
+
+// Do not fail mutating operations (AddHost, UpdateHost, DelHost, AddAlias) when
+// Netmagis answers without error page but the success marker is not found (e.g.
+// after a theme change): the resulting state is checked with a verification read
+// instead, and an error is only returned if it does not match.
+func WithLenientValidation() ClientOption {
+	return func(c *NetmagisClient) {
+		c.lenient = true
+	}
+}
+
+// Default parameters of AddHost and UpdateHost (e.g. `hinfo` or `ttl`), replacing
+// the library defaults for the parameters not given on each call:
+//
+//	netmagis.WithDefaultParams(map[string]interface{}{"hinfo": "PC/Linux", "ttl": 3600})
+func WithDefaultParams(params map[string]interface{}) ClientOption {
+	return func(c *NetmagisClient) {
+		c.defaultParams = map[string]interface{}{}
+		for field, value := range params {
+			c.defaultParams[field] = value
+		}
+	}
+}
+
+// Abandon an item of a batch operation (AddHosts, DelHosts, Reconcile, SetZoneTTL)
+// when it is not done after `timeout`, so a hung request does not stall the whole
+// batch: the item fails with an error wrapping context.DeadlineExceeded and the
+// batch continues. The timeout covers all the requests of an item, unlike the
+// client timeout (see WithTimeout) applying to each request.
+func WithBatchItemTimeout(timeout time.Duration) ClientOption {
+	return func(c *NetmagisClient) {
+		c.batchItemTimeout = timeout
+	}
+}
+
+// Send the read requests (Search, GetHost, ListHosts, ...) to the read-only replica
+// at `readUrl`, the writes (AddHost, UpdateHost, DelHost, ...) going to the primary
+// instance given to the constructor. The reads following a write within `maxLag`
+// (form tokens, verification reads, ...) are also sent to the primary, so changes
+// are read back despite the replication lag. The replica session is opened through
+// the CAS single sign-on when the client is created, which fails when no session
+// can be opened (e.g. with NewClientFromTicket, whose client holds no CAS session).
+func WithReadReplica(readUrl string, maxLag time.Duration) ClientOption {
+	return func(c *NetmagisClient) {
+		c.replica = &replica{url: readUrl, maxLag: maxLag}
+	}
+}
+
+// Use the REST API served at `apiUrl` (e.g. `https://netmagis.example.com/api`)
+// for the core operations (SearchAll, GetHost, AddHost, UpdateHost and DelHost),
+// authenticating with the API `token` (sent as a bearer token). The API is probed
+// on the first operation and, when it is not available, the HTML interface is
+// used as without this option; the probe is repeated after an HTTP error and an
+// authentication failure is returned (see useREST). Writes go through the same
+// retries and verifications as the forms; those of a client scoped to an
+// organization, of round-robin names and of several addresses (`naddr`) use the
+// forms. Other operations always use the HTML interface.
+func WithRESTAPI(apiUrl string, token string) ClientOption {
+	return func(c *NetmagisClient) {
+		c.rest = &restAPI{url: apiUrl, token: token}
+	}
+}
+
+// Use the view `idview` by default instead of the default view of the server,
+// retrieved when the client is created (the client creation fails when the /add
+// form selects no view). Operations accepting parameters (AddHost, UpdateHost,
+// DelHostParams) can target another view with the `idview` parameter.
+func WithDefaultView(idview int) ClientOption {
+	return func(c *NetmagisClient) {
+		c.defaultView = strconv.Itoa(idview)
+	}
+}
+
+// Check the DNS rules client-side before submitting, instead of relying on
+// Netmagis to refuse invalid operations: an alias is only added on a name without
+// records, an address is not added to an alias, and the targets of aliases, MX and
+// SRV records must be existing hosts. Violations are reported with an error of
+// kind ErrDNSRule. The checks cost additional searches.
+func WithStrictDNS() ClientOption {
+	return func(c *NetmagisClient) {
+		c.strictDNS = true
+	}
+}
+
+// Fail with an ErrValidation error when Netmagis answers a page which is not valid
+// UTF-8, instead of replacing the invalid sequences with U+FFFD.
+func WithStrictUTF8() ClientOption {
+	return func(c *NetmagisClient) {
+		c.strictUTF8 = true
+	}
+}
+
+// Confirm the state resulting from mutating operations (AddHost, UpdateHost,
+// DelHost, AddAlias) with a verification read after each successful submission,
+// regardless of the success marker. An error of kind ErrVerificationFailed is
+// returned when the state read back contradicts the expected outcome.
+func WithVerifyAfterWrite() ClientOption {
+	return func(c *NetmagisClient) {
+		c.verifyAfterWrite = true
+	}
+}
+
+// Call `handler` with the result of each successful mutating operation, e.g. for
+// surfacing the warnings displayed by Netmagis on partial successes.
+func WithResultHandler(handler func(OperationResult)) ClientOption {
+	return func(c *NetmagisClient) {
+		c.resultHandler = handler
+	}
+}
+
+// Call `handler` with each mutating operation submitted to Netmagis, successful or
+// not, and the exact form values sent (anti-CSRF tokens excluded; the forms never
+// contain credentials), e.g. for keeping an audit trail of the changes without
+// enabling the debug dumps. Operations not submitted (dry runs, refused by a
+// client-side check) are not reported.
+func WithAuditHandler(handler func(AuditEntry)) ClientOption {
+	return func(c *NetmagisClient) {
+		c.auditHandler = handler
+	}
+}
+
+// Hook called with each Netmagis request and its response, after the body is read
+// and before it is validated, e.g. for recording metrics or capturing fixtures.
+// On HTTP errors, `res` and `body` are nil and `err` is set; the body is a copy.
+type ResponseInterceptor func(req *http.Request, res *http.Response, body []byte, err error)
+
+// Call `interceptor` for each request of the client (see ResponseInterceptor),
+// including those failing.
+func WithResponseInterceptor(interceptor ResponseInterceptor) ClientOption {
+	return func(c *NetmagisClient) {
+		c.interceptor = interceptor
+	}
+}
+
+// Report each Netmagis request to `metrics` (see Metrics).
+func WithMetrics(metrics Metrics) ClientOption {
+	return func(c *NetmagisClient) {
+		c.metrics = metrics
+	}
+}
+
+// Limit the requests of the client (including its copies, see WithContext) to
+// `requests` per second, to avoid overloading Netmagis with bulk operations. A
+// zero or negative rate disables the limit.
+func WithRateLimit(requests float64) ClientOption {
+	return func(c *NetmagisClient) {
+		if requests <= 0 {
+			c.rateLimiter = nil
+			return
+		}
+		c.rateLimiter = &rateLimiter{interval: time.Duration(float64(time.Second) / requests)}
+	}
+}
+
+// Run at most `n` items of batch operations (AddHosts, DelHosts, SetZoneTTL,
+// Reconcile) at a time, across all the batch calls of the client and of its
+// copies (1 by default: items run sequentially). It composes with WithRateLimit:
+// the concurrency bounds the requests in flight while the rate limit spaces their
+// starts.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(c *NetmagisClient) {
+		if n < 1 {
+			n = 1
+		}
+		c.semaphore = make(chan struct{}, n)
+	}
+}
+
+// Use `clock` as source of time instead of the real clock, so time-dependent
+// behaviors (polling, backoff, rate limiting, ...) can be tested without real
+// sleeps.
+func WithClock(clock Clock) ClientOption {
+	return func(c *NetmagisClient) {
+		c.clock = clock
+	}
+}
+
+// Retry requests failing with an HTTP error (kind ErrTransport) up to `retries`
+// times, waiting `backoff` (doubled after each attempt, 500ms when zero) between
+// attempts. Mutating operations are only resubmitted after a verification read
+// shows that the previous attempt did not succeed, to avoid duplicate submissions.
+func WithRetries(retries int, backoff time.Duration) ClientOption {
+	return func(c *NetmagisClient) {
+		if backoff == 0 {
+			backoff = defaultRetryBackoff
+		}
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
@@ -0,0 +1,17 @@
+package netmagis
+
+import "testing"
+
+func TestOptionsDefaults(t *testing.T) {
+	client := newTestClient(
+		t, fixtureHandler(t, map[string]string{}),
+		WithEndpoints(Endpoints{Search: "/netmagis/search"}),
+		WithMarkers(Markers{HostAdded: "Machine ajoutée."}),
+	)
+	if client.endpoints.Search != "/netmagis/search" || client.endpoints.Mod != DefaultEndpoints.Mod {
+		t.Errorf("unexpected endpoints: %+v", client.endpoints)
+	}
+	if client.markers.HostAdded != "Machine ajoutée." || client.markers.HostRemoved != DefaultMarkers.HostRemoved {
+		t.Errorf("unexpected markers: %+v", client.markers)
+	}
+}
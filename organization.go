@@ -0,0 +1,44 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"net/url"
+	"strings"
+)
+
+// Form field scoping an operation to an organization. Stock Netmagis forms have
+// no such field (organizations are attributes of the networks, managed on
+// /admref?type=org), so it is only submitted to customized instances whose forms
+// declare it (see prepareForm).
+const organizationField = "org"
+
+// List the organizations defined in Netmagis, from the organizations
+// administration page, for use with WithOrganization.
+func (c *NetmagisClient) ListOrganizations() ([]string, error) {
+	body, err := c.Call(
+		c.endpoints.AdmRef,
+		url.Values{"type": {"org"}},
+		func(body string) bool { return true },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, &NetmagisError{
+			msg: fmt.Sprintf("unable to parse /admref HTML response: %s", err.Error()),
+		}
+	}
+
+	organizations := []string{}
+	for _, table := range htmlquery.Find(doc, "//table") {
+		for _, row := range parseTable(table) {
+			if name := row["name"]; name != "" {
+				organizations = append(organizations, name)
+			}
+		}
+	}
+	return organizations, nil
+}
@@ -0,0 +1,35 @@
+package netmagis
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestOrganizationDeclaredField(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, modHandler(t, "mod_host_org.html", &submitted), WithOrganization("campus"))
+	if err := client.SetComment("www.example.com", "new comment"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 || submitted[0].Get(organizationField) != "campus" {
+		t.Errorf("expected the organization to be submitted, got %v", submitted)
+	}
+}
+
+func TestOrganizationUndeclaredField(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, addFlowHandler(t, map[string]string{
+		"add-host": "add_success.html",
+	}, &submitted))
+
+	err := client.ForOrganization("campus").AddHost("new.example.com", "192.0.2.30", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 {
+		t.Fatalf("expected 1 submission, got %d", len(submitted))
+	}
+	if _, found := submitted[0][organizationField]; found {
+		t.Errorf("organization submitted to a form without organization field: %v", submitted[0])
+	}
+}
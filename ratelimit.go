@@ -0,0 +1,44 @@
+package netmagis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter spacing the requests of a client (and of its copies) by a minimum
+// interval (see WithRateLimit).
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Wait for the next request slot.
+func (c *NetmagisClient) waitRateLimit() error {
+	limiter := c.rateLimiter
+	if limiter == nil {
+		return nil
+	}
+
+	clock := c.clockOrDefault()
+	limiter.mutex.Lock()
+	now := clock.Now()
+	slot := limiter.next
+	if slot.Before(now) {
+		slot = now
+	}
+	limiter.next = slot.Add(limiter.interval)
+	limiter.mutex.Unlock()
+
+	if delay := slot.Sub(now); delay > 0 {
+		select {
+		case <-clock.After(delay):
+		case <-c.context().Done():
+			return &NetmagisError{
+				msg: fmt.Sprintf("waiting for rate limit interrupted: %s", c.context().Err()),
+			}
+		}
+	}
+	return nil
+}
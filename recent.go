@@ -0,0 +1,97 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Maximum number of pages crawled by RecentChanges.
+const maxRecentPages = 100
+
+// Modification of a record, from the recent modifications page.
+type RecentChange struct {
+	Name   string
+	Date   time.Time
+	Author string
+	// Operation, when given (e.g. "add", "mod", "del")
+	Action string
+}
+
+// List the records modified since `since` (most recent first), from the recent
+// modifications page of Netmagis. The pages are followed until reaching changes
+// older than `since`.
+func (c *NetmagisClient) RecentChanges(since time.Time) ([]RecentChange, error) {
+	changes := []RecentChange{}
+	query := url.Values{}
+	for page := 0; page < maxRecentPages; page++ {
+		body, err := c.Call(c.endpoints.Recent, query, func(body string) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := htmlquery.Parse(strings.NewReader(body))
+		if err != nil {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf("unable to parse %s HTML response: %s", c.endpoints.Recent, err.Error()),
+			}
+		}
+
+		older := false
+		for _, table := range htmlquery.Find(doc, "//table") {
+			for _, row := range parseTable(table) {
+				change, ok := parseRecentChange(row)
+				if !ok {
+					continue
+				}
+				if change.Date.Before(since) {
+					older = true
+					continue
+				}
+				changes = append(changes, change)
+			}
+		}
+		if older {
+			break
+		}
+
+		// Follow the link to the next page, if any
+		next, found := nextPageQuery(doc)
+		if !found {
+			break
+		}
+		query = next
+	}
+	return changes, nil
+}
+
+// Build a RecentChange from a row of the recent modifications table. Rows without
+// name or parsable date are rejected.
+func parseRecentChange(row map[string]string) (RecentChange, bool) {
+	change := RecentChange{}
+	for _, field := range []string{"name", "host", "fqdn"} {
+		if change.Name == "" {
+			change.Name = row[field]
+		}
+	}
+	for _, field := range []string{"date", "modified", "last_modification", "modification_date"} {
+		if change.Date.IsZero() && row[field] != "" {
+			change.Date, change.Author = parseNetmagisDate(row[field])
+		}
+	}
+	for _, field := range []string{"author", "user", "login", "modified_by"} {
+		if row[field] != "" {
+			change.Author = row[field]
+			break
+		}
+	}
+	for _, field := range []string{"action", "operation", "type"} {
+		if row[field] != "" {
+			change.Action = row[field]
+			break
+		}
+	}
+	return change, change.Name != "" && !change.Date.IsZero()
+}
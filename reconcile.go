@@ -0,0 +1,146 @@
+package netmagis
+
+import (
+	"fmt"
+)
+
+// Fields of a desired host (see Reconcile) which are not host form parameters.
+var reconcileFields = map[string]bool{
+	"name":       true,
+	"ip_address": true,
+	"absent":     true,
+}
+
+// Action applied (or to apply, in dry-run mode) on a host by Reconcile.
+type ChangeAction string
+
+const (
+	ChangeNone   ChangeAction = "none"
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Change of a host computed by Reconcile. `Err` is set when the change could not
+// be computed or applied.
+type HostChange struct {
+	Name   string
+	Action ChangeAction
+	Diffs  []FieldDiff
+	Err    error
+}
+
+// Converge the hosts to the `desired` states, applying the minimal change for each
+// of them, and return the changes. A desired host is given by its `name` (FQDN)
+// and the form parameters to enforce (ttl, mac, iddhcpprof, hinfo, comment,
+// respname, respmail, sendsmtp); parameters not given are left untouched. The
+// `ip_address` field is required for creating a missing host (it is not compared
+// on existing hosts), and `absent: true` requests the deletion of the host.
+//
+// With `dryRun`, nothing is modified and the changes report what would be done.
+// A failing host does not stop the reconciliation: the error is reported in its
+// change, and an error summarizing the failures is returned.
+func (c *NetmagisClient) Reconcile(desired []Host, dryRun bool) ([]HostChange, error) {
+	changes := make([]HostChange, len(desired))
+	errs := c.forEach(len(desired), func(idx int) error {
+		return c.runItem(func(client *NetmagisClient) error {
+			changes[idx] = client.reconcileHost(desired[idx], dryRun)
+			return changes[idx].Err
+		})
+	})
+
+	failures := 0
+	for idx, err := range errs {
+		if err == nil {
+			continue
+		}
+		// The host may not have been processed (interrupted)
+		if changes[idx].Name == "" {
+			name, _ := desired[idx]["name"].(string)
+			changes[idx] = HostChange{Name: name, Action: ChangeNone}
+		}
+		changes[idx].Err = err
+		failures++
+	}
+
+	if failures > 0 {
+		return changes, &NetmagisError{
+			msg: fmt.Sprintf("Reconcile: %d of %d hosts failed", failures, len(desired)),
+		}
+	}
+	return changes, nil
+}
+
+func (c *NetmagisClient) reconcileHost(desired Host, dryRun bool) HostChange {
+	name, _ := desired["name"].(string)
+	change := HostChange{Name: name, Action: ChangeNone}
+	if name == "" {
+		change.Err = &NetmagisError{msg: "Reconcile: desired host without name"}
+		return change
+	}
+
+	params := Host{}
+	for field, value := range desired {
+		if !reconcileFields[field] {
+			params[field] = value
+		}
+	}
+
+	absent, ok := try(desired, "absent", false).(bool)
+	if !ok {
+		change.Err = &NetmagisError{
+			msg: fmt.Sprintf("Reconcile: invalid `absent` value for '%s' (bool expected)", name),
+		}
+		return change
+	}
+
+	current, err := c.GetHost(name)
+	if err != nil {
+		change.Err = err
+		return change
+	}
+
+	switch {
+	case absent:
+		if current == nil {
+			return change
+		}
+		change.Action = ChangeDelete
+		if !dryRun {
+			change.Err = c.DelHost(name)
+		}
+
+	case current == nil:
+		change.Action = ChangeCreate
+		ip, _ := desired["ip_address"].(string)
+		if ip == "" {
+			change.Err = &NetmagisError{
+				msg: fmt.Sprintf("Reconcile: no ip_address given for creating '%s'", name),
+			}
+			return change
+		}
+		change.Diffs = HostDiff(Host{}, params)
+		if !dryRun {
+			change.Err = c.AddHost(name, ip, params)
+		}
+
+	default:
+		change.Diffs = HostDiff(current, params)
+		if len(change.Diffs) == 0 {
+			return change
+		}
+		change.Action = ChangeUpdate
+		idrr, err := hostIdrr(current)
+		if err != nil {
+			change.Err = err
+			return change
+		}
+		if !dryRun {
+			for _, diff := range change.Diffs {
+				current[diff.Field] = diff.Desired
+			}
+			change.Err = c.UpdateHost(name, idrr, current)
+		}
+	}
+	return change
+}
@@ -0,0 +1,434 @@
+package netmagis
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record types supported by AddRecord. Netmagis manages addresses (A, AAAA),
+// aliases (CNAME), mail exchangers (MX) and, on versions providing the SRV form,
+// services (SRV). Other types (TXT, SSHFP, CAA, ...) are not manageable through its
+// web interface.
+var SupportedRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "SRV"}
+
+// Name of a SRV record: service and protocol labels followed by a domain
+var srvNameRegexp = regexp.MustCompile(
+	`^(_[a-zA-Z0-9-]+\._(tcp|udp|tls|sctp))\.((?:[0-9a-zA-Z-]{1,63}\.)+[a-zA-Z]{2,63})$`,
+)
+
+// DNS record.
+type Record struct {
+	Name  string
+	Type  string
+	Value string
+	// Netmagis resource record id of the name (0 when not retrieved)
+	Idrr int
+	// TTL of the record (0 for the zone default)
+	TTL int
+}
+
+// Return the records of `fqdn`, from its search result: addresses (A, AAAA), alias
+// (CNAME), mail exchangers (MX) and services (SRV).
+func (c *NetmagisClient) GetRecords(fqdn string) ([]Record, error) {
+	host, err := c.Search(fqdn)
+	if err != nil || host == nil {
+		return []Record{}, err
+	}
+	return searchRecords(fqdn, host), nil
+}
+
+// Build the records of `fqdn` from its search result.
+func searchRecords(fqdn string, host Host) []Record {
+	records := []Record{}
+	if host["is_alias"].(bool) {
+		return append(records, Record{Name: fqdn, Type: "CNAME", Value: host["name"].(string)})
+	}
+
+	ttl, _ := host["ttl"].(int)
+	for _, address := range searchValues(host, "ip_addresses", "ip_address") {
+		recordType := "AAAA"
+		if net.ParseIP(address).To4() != nil {
+			recordType = "A"
+		}
+		records = append(records, Record{Name: fqdn, Type: recordType, Value: address, TTL: ttl})
+	}
+	for _, field := range []string{"mx", "mail_relays"} {
+		if value, ok := host[field].(string); ok && value != "" {
+			records = append(records, Record{Name: fqdn, Type: "MX", Value: value, TTL: ttl})
+		}
+	}
+	if value, ok := host["srv"].(string); ok && value != "" {
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				records = append(records, Record{Name: fqdn, Type: "SRV", Value: line, TTL: ttl})
+			}
+		}
+	}
+	return records
+}
+
+// Return the types of the records present for `fqdn` (among A, AAAA, CNAME, MX and
+// SRV, see GetRecords), sorted, e.g. for checking that a name has no address before
+// making it an alias (DNS forbids a CNAME alongside other records). The result is
+// empty when the name does not exist. TXT records are not managed by Netmagis, so
+// they are never reported.
+func (c *NetmagisClient) GetRecordTypes(fqdn string) ([]string, error) {
+	records, err := c.GetRecords(fqdn)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	types := []string{}
+	for _, record := range records {
+		if !found[record.Type] {
+			found[record.Type] = true
+			types = append(types, record.Type)
+		}
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// Return the value of the mandatory field `field` of `rdata`.
+func rdataField(rdata map[string]string, recordType string, field string) (string, error) {
+	value, found := rdata[field]
+	if !found || value == "" {
+		return "", &NetmagisError{
+			msg: fmt.Sprintf("missing field '%s' in %s record data", field, recordType),
+		}
+	}
+	return value, nil
+}
+
+// Types of the AddHost parameters accepted in the data of address records (see
+// AddRecord).
+var (
+	rdataStringParams = map[string]bool{
+		"mac": true, "hinfo": true, "comment": true, "respname": true, "respmail": true, "confirm": true,
+	}
+	rdataIntParams  = map[string]bool{"ttl": true, "naddr": true, "iddhcpprof": true, "idview": true}
+	rdataBoolParams = map[string]bool{"sendsmtp": true, "multiple": true, "force": true, "allow_special": true}
+)
+
+// Convert the data of an address record (besides its `ip`) to AddHost parameters
+// of the expected types (e.g. "true" to a bool for `multiple`).
+func addressRdataParams(rdata map[string]string) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for field, value := range rdata {
+		switch {
+		case field == "ip":
+		case rdataStringParams[field]:
+			params[field] = value
+		case rdataIntParams[field]:
+			intValue, err := strToInt(value)
+			if err != nil {
+				return nil, &NetmagisError{msg: fmt.Sprintf("invalid integer '%s' for field '%s'", value, field)}
+			}
+			params[field] = intValue
+		case rdataBoolParams[field]:
+			boolValue, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, &NetmagisError{msg: fmt.Sprintf("invalid boolean '%s' for field '%s'", value, field)}
+			}
+			params[field] = boolValue
+		default:
+			return nil, &NetmagisError{msg: fmt.Sprintf("unknown field '%s' in address record data", field)}
+		}
+	}
+	return params, nil
+}
+
+// Add a record of type `recordType` to `fqdn`. The record data depends on the type:
+//   - A, AAAA: `ip` (mandatory), and the AddHost parameters (e.g. `comment`,
+//     `ttl` or `multiple`, converted to their type); other fields are rejected
+//   - CNAME: `target` (mandatory)
+//   - MX: `target` and `priority` (mandatory)
+//   - SRV: `priority`, `weight`, `port` and `target` (mandatory), see AddSRV
+//
+// See SupportedRecordTypes.
+func (c *NetmagisClient) AddRecord(fqdn string, recordType string, rdata map[string]string) error {
+	recordType = strings.ToUpper(recordType)
+	fqdn = normalizeFqdn(fqdn)
+	switch recordType {
+	case "A", "AAAA":
+		ip, err := rdataField(rdata, recordType, "ip")
+		if err != nil {
+			return err
+		}
+		parsedIp := net.ParseIP(ip)
+		if parsedIp == nil || (parsedIp.To4() != nil) != (recordType == "A") {
+			return &NetmagisError{msg: fmt.Sprintf("invalid %s record address '%s'", recordType, ip)}
+		}
+
+		params, err := addressRdataParams(rdata)
+		if err != nil {
+			return err
+		}
+		return c.AddHost(fqdn, ip, params)
+
+	case "CNAME":
+		target, err := rdataField(rdata, recordType, "target")
+		if err != nil {
+			return err
+		}
+		if !checkFqdn(target) {
+			return &NetmagisError{msg: fmt.Sprintf("CNAME target '%s' is not a FQDN", target)}
+		}
+		return c.AddAlias(fqdn, target)
+
+	case "MX":
+		target, err := rdataField(rdata, recordType, "target")
+		if err != nil {
+			return err
+		}
+		if !checkFqdn(target) {
+			return &NetmagisError{msg: fmt.Sprintf("MX target '%s' is not a FQDN", target)}
+		}
+		priority, err := rdataField(rdata, recordType, "priority")
+		if err != nil {
+			return err
+		}
+		if p, err := strconv.Atoi(priority); err != nil || p < 0 || p > 65535 {
+			return &NetmagisError{msg: fmt.Sprintf("invalid MX priority '%s'", priority)}
+		}
+		return c.addMX(fqdn, priority, target)
+
+	case "SRV":
+		values := []int{}
+		for _, field := range []string{"priority", "weight", "port"} {
+			value, err := rdataField(rdata, recordType, field)
+			if err != nil {
+				return err
+			}
+			intValue, err := strconv.Atoi(value)
+			if err != nil {
+				return &NetmagisError{msg: fmt.Sprintf("invalid SRV %s '%s'", field, value)}
+			}
+			values = append(values, intValue)
+		}
+		target, err := rdataField(rdata, recordType, "target")
+		if err != nil {
+			return err
+		}
+		return c.AddSRV(fqdn, values[0], values[1], values[2], target)
+	}
+
+	return &NetmagisError{
+		msg: fmt.Sprintf(
+			"unsupported record type '%s' (supported: %s)",
+			recordType, strings.Join(SupportedRecordTypes, ", "),
+		),
+	}
+}
+
+func (c *NetmagisClient) addMX(fqdn string, priority string, target string) error {
+	target = normalizeFqdn(target)
+	if err := c.checkTargetRules("MX", target); err != nil {
+		return err
+	}
+	name, domain := c.splitFqdn(fqdn)
+	targetName, targetDomain := c.splitFqdn(target)
+
+	formData := url.Values{
+		"action":    {"add-mx"},
+		"name":      {name},
+		"domain":    {domain},
+		"prio":      {priority},
+		"nameref":   {targetName},
+		"domainref": {targetDomain},
+		"idview":    {c.DefaultView()},
+	}
+	c.prepareForm(c.endpoints.Add, url.Values{}, formData)
+
+	checkFunc := confirmationCheck(c.markers.MXAdded)
+	verifyFunc := func() (bool, error) { return c.onPrimary().recordExists(fqdn, "MX", target) }
+
+	return c.submit(c.endpoints.Add, formData, checkFunc, verifyFunc)
+}
+
+// Return the address records (A and AAAA) of `fqdn` with their idrr, e.g. for
+// checking whether a name is round-robin before adding (see the `multiple`
+// parameter of AddHost) or removing one of its addresses. The search result of a
+// host also gives the number of addresses in its `naddr` field.
+func (c *NetmagisClient) HostAddresses(fqdn string) ([]Record, error) {
+	records, err := c.GetRecords(fqdn)
+	if err != nil {
+		return nil, err
+	}
+	addresses := []Record{}
+	for _, record := range records {
+		if record.Type == "A" || record.Type == "AAAA" {
+			addresses = append(addresses, record)
+		}
+	}
+	if len(addresses) == 0 {
+		return addresses, nil
+	}
+
+	// All the addresses of a name share its idrr
+	form, err := c.GetHost(fqdn)
+	if err != nil {
+		return nil, err
+	}
+	if form != nil {
+		idrr, err := hostIdrr(form)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range addresses {
+			addresses[idx].Idrr = idrr
+		}
+	}
+	return addresses, nil
+}
+
+// Return all the records referencing the address `ip` across names: the forward
+// record (A or AAAA) and the reverse record (PTR) of each name using it (there are
+// several names for round-robin DNS), with their idrr.
+func (c *NetmagisClient) ListRecordsByIP(ip string) ([]Record, error) {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("invalid IP address '%s'", ip)}
+	}
+	forwardType := "AAAA"
+	if parsedIp.To4() != nil {
+		forwardType = "A"
+	}
+
+	hosts, err := c.SearchAll(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, host := range hosts {
+		name, _ := host["name"].(string)
+		if name == "" {
+			continue
+		}
+		form, err := c.GetHost(name)
+		if err != nil {
+			return nil, err
+		}
+		idrr := 0
+		if form != nil {
+			if idrr, err = hostIdrr(form); err != nil {
+				return nil, err
+			}
+		}
+		records = append(
+			records,
+			Record{Name: name, Type: forwardType, Value: ip, Idrr: idrr},
+			Record{Name: ip, Type: "PTR", Value: name, Idrr: idrr},
+		)
+	}
+	return records, nil
+}
+
+// Split a SRV record name (e.g. `_sip._tcp.example.com`) into its service/protocol
+// labels (`_sip._tcp`) and domain.
+func (c *NetmagisClient) splitSRVName(name string) (string, string, error) {
+	submatch := srvNameRegexp.FindStringSubmatch(name)
+	if submatch == nil {
+		return "", "", &NetmagisError{
+			msg: fmt.Sprintf("invalid SRV name '%s' (expected _service._proto.domain)", name),
+		}
+	}
+	// Names below the domain are kept with the service labels
+	label, domain := splitFqdnZone(name, c.zones)
+	if !strings.HasPrefix(label, submatch[1]) {
+		label, domain = submatch[1], submatch[3]
+	}
+	return label, domain, nil
+}
+
+// Check that `name` is the name of a SRV record (e.g. `_sip._tcp.example.com`),
+// whose service labels are not accepted by checkFqdn.
+func checkSRVName(name string) bool {
+	return srvNameRegexp.MatchString(normalizeFqdn(name))
+}
+
+// Validate the fields of a SRV record.
+func checkSRV(priority int, weight int, port int, target string) error {
+	if priority < 0 || priority > 65535 {
+		return &NetmagisError{msg: fmt.Sprintf("invalid SRV priority %d (0-65535)", priority)}
+	}
+	if weight < 0 || weight > 65535 {
+		return &NetmagisError{msg: fmt.Sprintf("invalid SRV weight %d (0-65535)", weight)}
+	}
+	if port < 1 || port > 65535 {
+		return &NetmagisError{msg: fmt.Sprintf("invalid SRV port %d (1-65535)", port)}
+	}
+	if !checkFqdn(target) {
+		return &NetmagisError{msg: fmt.Sprintf("SRV target '%s' is not a FQDN", target)}
+	}
+	return nil
+}
+
+// Add a SRV record `name` (e.g. `_sip._tcp.example.com`) pointing to `target`.
+// This requires a Netmagis version providing the SRV form.
+func (c *NetmagisClient) AddSRV(name string, priority int, weight int, port int, target string) error {
+	name, target = normalizeFqdn(name), normalizeFqdn(target)
+	if err := checkSRV(priority, weight, port, target); err != nil {
+		return err
+	}
+	label, domain, err := c.splitSRVName(name)
+	if err != nil {
+		return err
+	}
+	if err := c.checkTargetRules("SRV", target); err != nil {
+		return err
+	}
+	targetName, targetDomain := c.splitFqdn(target)
+
+	formData := url.Values{
+		"action":    {"add-srv"},
+		"name":      {label},
+		"domain":    {domain},
+		"prio":      {strconv.Itoa(priority)},
+		"weight":    {strconv.Itoa(weight)},
+		"port":      {strconv.Itoa(port)},
+		"nameref":   {targetName},
+		"domainref": {targetDomain},
+		"idview":    {c.DefaultView()},
+	}
+	c.prepareForm(c.endpoints.Add, url.Values{}, formData)
+
+	checkFunc := confirmationCheck(c.markers.SRVAdded)
+	verifyFunc := func() (bool, error) { return c.onPrimary().recordExists(name, "SRV", target) }
+
+	return c.submit(c.endpoints.Add, formData, checkFunc, verifyFunc)
+}
+
+// Remove the SRV record `name` pointing to `target`.
+func (c *NetmagisClient) DelSRV(name string, target string) error {
+	name, target = normalizeFqdn(name), normalizeFqdn(target)
+	label, domain, err := c.splitSRVName(name)
+	if err != nil {
+		return err
+	}
+	targetName, targetDomain := c.splitFqdn(target)
+
+	formData := url.Values{
+		"action":    {"del-srv"},
+		"name":      {label},
+		"domain":    {domain},
+		"nameref":   {targetName},
+		"domainref": {targetDomain},
+		"idviews":   {c.DefaultView()},
+	}
+	c.prepareForm(c.endpoints.Del, url.Values{}, formData)
+
+	checkFunc := confirmationCheck(c.markers.HostRemoved)
+	verifyFunc := func() (bool, error) {
+		exists, err := c.onPrimary().recordExists(name, "SRV", target)
+		return !exists, err
+	}
+
+	return c.submit(c.endpoints.Del, formData, checkFunc, verifyFunc)
+}
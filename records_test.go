@@ -0,0 +1,182 @@
+package netmagis
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestAddressRdataParams(t *testing.T) {
+	params, err := addressRdataParams(map[string]string{
+		"ip":            "192.0.2.1",
+		"comment":       "web server",
+		"ttl":           "3600",
+		"naddr":         "2",
+		"multiple":      "true",
+		"allow_special": "1",
+		"force":         "false",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]interface{}{
+		"comment":       "web server",
+		"ttl":           3600,
+		"naddr":         2,
+		"multiple":      true,
+		"allow_special": true,
+		"force":         false,
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, got %v", expected, params)
+	}
+}
+
+func TestAddressRdataParamsErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown field":   {"ip": "192.0.2.1", "coment": "typo"},
+		"invalid integer": {"ip": "192.0.2.1", "ttl": "one hour"},
+		"invalid boolean": {"ip": "192.0.2.1", "multiple": "maybe"},
+	}
+	for name, rdata := range tests {
+		if _, err := addressRdataParams(rdata); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAddRecordInvalidData(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	tests := map[string]struct {
+		recordType string
+		rdata      map[string]string
+	}{
+		"unknown field":    {"A", map[string]string{"ip": "192.0.2.1", "multipel": "true"}},
+		"IPv6 in A record": {"A", map[string]string{"ip": "2001:db8::1"}},
+		"IPv4 in AAAA":     {"AAAA", map[string]string{"ip": "192.0.2.1"}},
+		"missing target":   {"CNAME", map[string]string{}},
+		"invalid priority": {"MX", map[string]string{"target": "mx.example.com", "priority": "high"}},
+		"unsupported type": {"TXT", map[string]string{"text": "v=spf1 -all"}},
+	}
+	for name, test := range tests {
+		if err := client.AddRecord("www.example.com", test.recordType, test.rdata); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestHostAddresses(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{
+		"/search": "search_rr.html",
+		"/mod":    "mod_host.html",
+	}))
+
+	host, err := client.Search("rr.example.com")
+	if err != nil {
+		t.Fatalf("Search: unexpected error: %s", err)
+	}
+	if host["naddr"] != 3 {
+		t.Errorf("expected naddr 3, got %v", host["naddr"])
+	}
+
+	addresses, err := client.HostAddresses("rr.example.com")
+	if err != nil {
+		t.Fatalf("HostAddresses: unexpected error: %s", err)
+	}
+	expected := []string{"192.0.2.11", "192.0.2.12", "192.0.2.13"}
+	if len(addresses) != len(expected) {
+		t.Fatalf("expected %d addresses, got %v", len(expected), addresses)
+	}
+	for idx, address := range addresses {
+		if address.Type != "A" || address.Value != expected[idx] || address.Idrr != 1234 || address.TTL != 300 {
+			t.Errorf("address %d: expected A %s (idrr 1234, TTL 300), got %+v", idx, expected[idx], address)
+		}
+	}
+}
+
+func TestGetRecordsSRV(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": "search_srv.html"}))
+	records, err := client.GetRecords("_sip._tcp.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []Record{
+		{Name: "_sip._tcp.example.com", Type: "SRV", Value: "10 5 5060 sip.example.com", TTL: 3600},
+		{Name: "_sip._tcp.example.com", Type: "SRV", Value: "20 5 5060 sip2.example.com", TTL: 3600},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+// Return a handler of the SRV forms, answering the submissions of `uri` with the
+// fixture `result` and the searches with `search` once submitted.
+func srvHandler(t *testing.T, uri string, result string, search string, submitted *[]url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == uri && r.PostForm.Get("action") != "":
+			*submitted = append(*submitted, r.PostForm)
+			w.Write([]byte(fixture(t, result)))
+		case r.URL.Path == "/search" && len(*submitted) > 0:
+			w.Write([]byte(fixture(t, search)))
+		case r.URL.Path == "/search":
+			http.NotFound(w, r)
+		default:
+			w.Write([]byte("<html></html>"))
+		}
+	}
+}
+
+func TestAddSRVVerified(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(
+		t, srvHandler(t, "/add", "add_srv_success.html", "search_srv.html", &submitted),
+		WithVerifyAfterWrite(),
+	)
+	if err := client.AddSRV("_sip._tcp.example.com", 10, 5, 5060, "sip.example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 {
+		t.Fatalf("expected 1 submission, got %d", len(submitted))
+	}
+	for field, value := range map[string]string{"name": "_sip._tcp", "domain": "example.com", "port": "5060"} {
+		if submitted[0].Get(field) != value {
+			t.Errorf("%s: expected %q, got %q", field, value, submitted[0].Get(field))
+		}
+	}
+}
+
+func TestDelSRVVerified(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(
+		t, srvHandler(t, "/del", "del_srv_success.html", "search_notfound.html", &submitted),
+		WithVerifyAfterWrite(),
+	)
+	if err := client.DelSRV("_sip._tcp.example.com", "sip.example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 || submitted[0].Get("action") != "del-srv" {
+		t.Errorf("expected 1 del-srv submission, got %v", submitted)
+	}
+}
+
+func TestCheckSRVName(t *testing.T) {
+	tests := map[string]bool{
+		"_sip._tcp.example.com":  true,
+		"_sip._tcp.example.com.": true,
+		"_LDAP._TCP.Example.COM": true,
+		"_sip._tcp":              false,
+		"sip._tcp.example.com":   false,
+		"_sip._foo.example.com":  false,
+		"_sip._tcp..example.com": false,
+	}
+	for name, expected := range tests {
+		if valid := checkSRVName(name); valid != expected {
+			t.Errorf("%q: expected %t, got %t", name, expected, valid)
+		}
+	}
+}
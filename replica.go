@@ -0,0 +1,102 @@
+package netmagis
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Read-only replica of the instance (see WithReadReplica). It is shared by the
+// copies of the client, so a write through any of them is seen by all.
+type replica struct {
+	url    string
+	maxLag time.Duration
+	mutex  sync.Mutex
+	// Time of the last write, reads being sent to the primary for `maxLag` after
+	lastWrite time.Time
+}
+
+// Record that a write is being done, so the following reads (form tokens,
+// verification reads, ...) are sent to the primary instance.
+func (c *NetmagisClient) markWrite() {
+	if c.replica == nil {
+		return
+	}
+	c.replica.mutex.Lock()
+	defer c.replica.mutex.Unlock()
+	c.replica.lastWrite = c.clockOrDefault().Now()
+}
+
+// Return a copy of the client sending all its requests to the primary instance,
+// for the writes.
+func (c *NetmagisClient) onPrimary() *NetmagisClient {
+	if c.replica == nil {
+		return c
+	}
+	clone := *c
+	clone.replica = nil
+	return &clone
+}
+
+// Return the URL of a request to `uri`: on the replica (see WithReadReplica) unless
+// a write was done within the replication lag, on the primary instance otherwise.
+func (c *NetmagisClient) requestUrl(uri string) string {
+	if c.replica == nil {
+		return c.JoinUrl(uri)
+	}
+	c.replica.mutex.Lock()
+	recentWrite := c.clockOrDefault().Now().Sub(c.replica.lastWrite) < c.replica.maxLag
+	c.replica.mutex.Unlock()
+	if recentWrite {
+		return c.JoinUrl(uri)
+	}
+	return strings.TrimRight(c.replica.url, "/") + "/" + strings.Trim(uri, "/")
+}
+
+// Open a session on the replica, relying on the CAS single sign-on of the session
+// opened on the primary instance. An error is returned when no session is opened
+// (e.g. for a client created from a ticket, which holds no CAS session), rather
+// than sending the reads to an unauthenticated replica.
+func (c *NetmagisClient) connectReplica() error {
+	if c.replica == nil {
+		return nil
+	}
+	replicaUrl := strings.TrimRight(c.replica.url, "/") + "/"
+	startUrl := replicaUrl + strings.Trim(c.endpoints.Start, "/")
+	base, err := url.Parse(startUrl)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("invalid replica URL: %s", err.Error())}
+	}
+	cas := CasClient{
+		HttpClient:   c.HttpClient,
+		Context:      c.context(),
+		MaxRedirects: c.casMaxRedirects,
+	}
+	if err := cas.followCallback(base, startUrl); err != nil {
+		return &NetmagisError{
+			msg: fmt.Sprintf("unable to open a session on the replica: %s", err.Error()),
+			err: err,
+		}
+	}
+
+	res, err := c.HttpClient.GetContext(c.context(), replicaUrl+strings.Trim(c.endpoints.Profile, "/"))
+	if err != nil {
+		return &NetmagisError{
+			msg: fmt.Sprintf("unable to check the session on the replica: %s", err.Error()),
+			err: err,
+		}
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		return &NetmagisError{
+			msg: fmt.Sprintf(
+				"no session opened on the replica (status: %s), CAS single sign-on unavailable",
+				res.Status,
+			),
+			kind: ErrPermissionDenied,
+		}
+	}
+	return nil
+}
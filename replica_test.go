@@ -0,0 +1,77 @@
+package netmagis
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Return the URL of a replica answering with `handler`.
+func newTestReplica(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestReplicaRetryVerifiedOnPrimary(t *testing.T) {
+	// The replica never shows the host, as if it lagged behind the primary
+	replicaUrl := newTestReplica(t, fixtureHandler(t, map[string]string{
+		"/mod":    "mod_notfound.html",
+		"/search": "search_notfound.html",
+	}))
+
+	submitted := []url.Values{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/add" && r.PostForm.Get("action") == "":
+			w.Write([]byte(fixture(t, "add_form.html")))
+		case r.URL.Path == "/add":
+			// The host is stored but the response is lost
+			submitted = append(submitted, r.PostForm)
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case r.URL.Path == "/search" && len(submitted) > 0:
+			w.Write([]byte(fixture(t, "search_host.html")))
+		case r.URL.Path == "/search":
+			w.Write([]byte(fixture(t, "search_notfound.html")))
+		case r.URL.Path == "/mod":
+			w.Write([]byte(fixture(t, "mod_notfound.html")))
+		default:
+			http.NotFound(w, r)
+		}
+	}, WithReadReplica(replicaUrl, 0), WithRetries(2, time.Millisecond))
+
+	if err := client.AddHost("www.example.com", "192.0.2.1", map[string]interface{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(submitted) != 1 {
+		t.Errorf("expected the host to be submitted once, got %d submissions", len(submitted))
+	}
+}
+
+func TestNewClientFromTicketReplica(t *testing.T) {
+	// The replica redirects to CAS, no single sign-on session being available
+	replicaUrl := newTestReplica(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/profile" {
+			http.Redirect(w, r, "https://cas.example.com/cas/login", http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := NewClientFromTicket(
+		server.URL, "ST-1-abc",
+		WithCASService(server.URL+"/start"), WithReadReplica(replicaUrl, time.Minute), WithDefaultView(1),
+	)
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected the replica session to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package netmagis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prefix of the comment of the placeholder hosts marking reserved addresses.
+// Netmagis has no reservation state of its own, so an address is reserved by
+// declaring a placeholder host with this comment.
+const ReservationCommentPrefix = "[reserved]"
+
+// Report whether a host comment marks a reservation.
+func isReservation(comment string) bool {
+	return strings.HasPrefix(strings.TrimSpace(comment), ReservationCommentPrefix)
+}
+
+// Reserve the address `ip` before the host using it is provisioned, by declaring
+// the placeholder host `fqdn` with a reservation comment (followed by `note`).
+// Reserved addresses are flagged with `reserved: true` in ListHosts results, and
+// released with ReleaseIP.
+func (c *NetmagisClient) ReserveIP(ip string, fqdn string, note string) error {
+	hosts, err := c.SearchAll(ip)
+	if err != nil {
+		return err
+	}
+	if len(hosts) > 0 {
+		name, _ := hosts[0]["name"].(string)
+		return &NetmagisError{msg: fmt.Sprintf("address %s is already used by '%s'", ip, name)}
+	}
+
+	comment := strings.TrimSpace(ReservationCommentPrefix + " " + note)
+	return c.AddHost(fqdn, ip, map[string]interface{}{"comment": comment})
+}
+
+// Release the reservation of the address `ip` made with ReserveIP, by deleting its
+// placeholder host. An error is returned when the address is used by a real host.
+func (c *NetmagisClient) ReleaseIP(ip string) error {
+	hosts, err := c.SearchAll(ip)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return &NetmagisError{msg: fmt.Sprintf("address %s is not reserved", ip), kind: ErrNotFound}
+	}
+
+	for _, host := range hosts {
+		name, _ := host["name"].(string)
+		comment, _ := host["comment"].(string)
+		if !isReservation(comment) {
+			return &NetmagisError{
+				msg: fmt.Sprintf("address %s is used by '%s', which is not a reservation", ip, name),
+			}
+		}
+	}
+	for _, host := range hosts {
+		if err := c.DelHost(host["name"].(string)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package netmagis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Paths of the REST API resources, relative to the URL given to WithRESTAPI.
+const (
+	restVersionPath = "/version"
+	restNamesPath   = "/names"
+)
+
+// REST API of the instance (see WithRESTAPI). It is shared by the copies of the
+// client, so it is probed once for all of them (see useREST).
+type restAPI struct {
+	url       string
+	token     string
+	mutex     sync.Mutex
+	probed    bool
+	available bool
+}
+
+// Name resource of the REST API.
+type restName struct {
+	Idrr       int      `json:"idrr,omitempty"`
+	Name       string   `json:"name"`
+	Domain     string   `json:"domain"`
+	View       string   `json:"view,omitempty"`
+	Addresses  []string `json:"addr,omitempty"`
+	Cname      string   `json:"cname,omitempty"`
+	Idview     int      `json:"idview,omitempty"`
+	TTL        int      `json:"ttl"`
+	Mac        string   `json:"mac,omitempty"`
+	Hinfo      string   `json:"hinfo,omitempty"`
+	Comment    string   `json:"comment,omitempty"`
+	Respname   string   `json:"respname,omitempty"`
+	Respmail   string   `json:"respmail,omitempty"`
+	Iddhcpprof int      `json:"iddhcpprof,omitempty"`
+	Sendsmtp   bool     `json:"sendsmtp"`
+}
+
+// Error returned by the REST API.
+type restError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// Report whether the operations go through the REST API: it is configured (see
+// WithRESTAPI) and answered the probe of its version resource. An API answering
+// the probe with an error page is not probed again, while the probe is repeated
+// by the next operation after an HTTP error (the HTML interface is used
+// meanwhile). An authentication failure (e.g. a wrong token) is returned instead
+// of silently falling back to the HTML interface.
+func (c *NetmagisClient) useREST() (bool, error) {
+	if c.rest == nil {
+		return false, nil
+	}
+	c.rest.mutex.Lock()
+	defer c.rest.mutex.Unlock()
+	if c.rest.probed {
+		return c.rest.available, nil
+	}
+
+	err := c.restCall(http.MethodGet, restVersionPath, nil, nil, nil)
+	switch {
+	case err == nil:
+		c.rest.probed, c.rest.available = true, true
+	case errors.Is(err, ErrPermissionDenied):
+		return false, &NetmagisError{
+			msg:  fmt.Sprintf("REST API authentication failed: %s", err.Error()),
+			kind: ErrPermissionDenied,
+			err:  err,
+		}
+	case errors.Is(err, ErrTransport):
+		return false, nil
+	default:
+		c.rest.probed = true
+	}
+	return c.rest.available, nil
+}
+
+// Report whether the writes go through the REST API (see useREST). Its resources
+// have no organization, so the writes of a client scoped to an organization (see
+// WithOrganization) go through the forms.
+func (c *NetmagisClient) useRESTWrites() (bool, error) {
+	if c.organization != "" {
+		return false, nil
+	}
+	return c.useREST()
+}
+
+// Send a request to the REST API and decode the JSON answer in `result` (if not nil).
+func (c *NetmagisClient) restCall(method string, path string, query url.Values, payload interface{}, result interface{}) error {
+	operation := "rest" + path
+	metrics := c.metricsOrDefault()
+	metrics.CallStarted(operation)
+	start := c.clockOrDefault().Now()
+
+	err := c.restSend(method, path, query, payload, result)
+	metrics.CallFinished(operation, c.clockOrDefault().Now().Sub(start), ErrorKindLabel(err))
+	if netmagisErr, ok := err.(*NetmagisError); ok && netmagisErr.op == "" {
+		netmagisErr.op = operation
+	}
+	return err
+}
+
+func (c *NetmagisClient) restSend(method string, path string, query url.Values, payload interface{}, result interface{}) error {
+	if err := c.waitRateLimit(); err != nil {
+		return err
+	}
+	uri := strings.TrimRight(c.rest.url, "/") + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			return &NetmagisError{msg: fmt.Sprintf("unable to encode %s request: %s", path, err.Error())}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(c.context(), method, uri, &body)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("invalid request: %s", err.Error())}
+	}
+	for name, values := range c.HttpClient.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.rest.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.rest.token)
+	}
+
+	res, err := c.HttpClient.HttpClient.Do(req)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("ClientError: %s", err.Error()), kind: ErrTransport, err: err}
+	}
+	defer res.Body.Close()
+	resBody, err := c.HttpClient.ReadBody(res)
+	if err != nil {
+		return &NetmagisError{msg: fmt.Sprintf("ClientError: %s", err.Error()), kind: ErrTransport, err: err}
+	}
+
+	if res.StatusCode >= 300 {
+		return restStatusError(method, path, res.StatusCode, resBody)
+	}
+	if result != nil && len(bytes.TrimSpace(resBody)) > 0 {
+		if err := json.Unmarshal(resBody, result); err != nil {
+			return &NetmagisError{
+				msg:  fmt.Sprintf("ValidationError: unexpected %s answer: %s", path, err.Error()),
+				kind: ErrValidation,
+			}
+		}
+	}
+	return nil
+}
+
+// Return the error for a REST answer with the status `status`.
+func restStatusError(method string, path string, status int, body []byte) error {
+	message := bodySnippet(string(body))
+	restErr := restError{}
+	if json.Unmarshal(body, &restErr) == nil {
+		if restErr.Message != "" {
+			message = restErr.Message
+		} else if restErr.Error != "" {
+			message = restErr.Error
+		}
+	}
+
+	var kind error
+	switch {
+	case status == http.StatusNotFound:
+		kind = ErrNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = ErrPermissionDenied
+	case status == http.StatusTooManyRequests:
+		kind = ErrQuotaExceeded
+	case status >= 500:
+		kind = ErrTransport
+	default:
+		kind = ErrValidation
+	}
+	return &NetmagisError{
+		msg:  fmt.Sprintf("NetmagisError: %s %s: HTTP %d: %s", method, path, status, message),
+		kind: kind,
+	}
+}
+
+// Return the names resources of `fqdn` (one per view).
+func (c *NetmagisClient) restNames(fqdn string) ([]restName, error) {
+	name, domain := c.splitFqdn(fqdn)
+	names := []restName{}
+	err := c.restCall(
+		http.MethodGet, restNamesPath, url.Values{"name": {name}, "domain": {domain}}, nil, &names,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// REST implementation of SearchAll.
+func (c *NetmagisClient) restSearchAll(host string) ([]Host, error) {
+	names := []restName{}
+	var err error
+	if checkIp(host) {
+		err = c.restCall(http.MethodGet, restNamesPath, url.Values{"addr": {host}}, nil, &names)
+	} else {
+		names, err = c.restNames(host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []Host{}
+	for _, name := range names {
+		hostParams := Host{
+			"name":         name.Name + "." + name.Domain,
+			"view":         name.View,
+			"ttl":          name.TTL,
+			"mac":          name.Mac,
+			"hinfo":        name.Hinfo,
+			"comment":      name.Comment,
+			"sendsmtp":     name.Sendsmtp,
+			"ip_addresses": name.Addresses,
+			"naddr":        len(name.Addresses),
+			"record_type":  RecordTypeHost,
+			"is_alias":     false,
+		}
+		if name.Cname != "" {
+			hostParams["name"] = name.Cname
+			hostParams["record_type"] = RecordTypeAlias
+			hostParams["is_alias"] = true
+		}
+		hosts = append(hosts, hostParams)
+	}
+	return hosts, nil
+}
+
+// REST implementation of GetHost.
+func (c *NetmagisClient) restGetHost(fqdn string, selector []string) (Host, error) {
+	names, err := c.restNames(fqdn)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	candidates := []hostCandidate{}
+	for _, name := range names {
+		if name.Cname != "" {
+			continue
+		}
+		hostParams := Host{
+			"idrr":       name.Idrr,
+			"name":       name.Name,
+			"view":       name.View,
+			"ttl":        name.TTL,
+			"mac":        name.Mac,
+			"hinfo":      name.Hinfo,
+			"comment":    name.Comment,
+			"respname":   name.Respname,
+			"respmail":   name.Respmail,
+			"iddhcpprof": strconv.Itoa(name.Iddhcpprof),
+			"sendsmtp":   name.Sendsmtp,
+		}
+		if hinfo, err := ParseHinfo(name.Hinfo); err == nil {
+			hostParams["hinfo_pair"] = hinfo
+		}
+		setMissingTimestamps(hostParams)
+		candidates = append(candidates, hostCandidate{host: hostParams, addresses: name.Addresses})
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	return selectCandidate(fqdn, candidates, selector)
+}
+
+// Build the name resource of `fqdn` from the AddHost/UpdateHost parameters.
+func (c *NetmagisClient) restNameParams(fqdn string, hinfo string, params map[string]interface{}) restName {
+	name, domain := c.splitFqdn(fqdn)
+	ttl, _ := strToInt(try(params, "ttl", -1))
+	iddhcpprof, _ := strToInt(try(params, "iddhcpprof", 0))
+	idview, _ := strconv.Atoi(c.viewParam(params))
+	return restName{
+		Name:       name,
+		Domain:     domain,
+		Idview:     idview,
+		TTL:        ttl,
+		Mac:        try(params, "mac", "").(string),
+		Hinfo:      hinfo,
+		Comment:    try(params, "comment", "").(string),
+		Respname:   try(params, "respname", "").(string),
+		Respmail:   try(params, "respmail", "").(string),
+		Iddhcpprof: iddhcpprof,
+		Sendsmtp:   strToBool(try(params, "sendsmtp", false)),
+	}
+}
+
+// Send the REST write `method` on `path` through the write pipeline (retries with
+// verification, lenient mode, audit, see submitRequest).
+func (c *NetmagisClient) restSubmit(method string, path string, name *restName, form url.Values, verify func() (bool, error)) error {
+	send := func() (string, error) {
+		var payload interface{}
+		if name != nil {
+			payload = name
+		}
+		return "", c.onPrimary().restCall(method, path, nil, payload, nil)
+	}
+	return c.submitRequest(path, form, send, verify)
+}
+
+// REST implementation of AddHost. The address is added as a new name: a name
+// already declared (see the `multiple` parameter) or several addresses (`naddr`)
+// go through the forms (see AddHost).
+func (c *NetmagisClient) restAddHost(fqdn string, ip string, hinfo string, params map[string]interface{}) error {
+	name := c.restNameParams(fqdn, hinfo, params)
+	name.Addresses = []string{ip}
+	verify := func() (bool, error) { return c.onPrimary().hostAdded(fqdn, ip) }
+	return c.restSubmit(http.MethodPost, restNamesPath, &name, restForm("add-host", name), verify)
+}
+
+// REST implementation of UpdateHost.
+func (c *NetmagisClient) restUpdateHost(fqdn string, idrr int, hinfo string, params map[string]interface{}) error {
+	name := c.restNameParams(fqdn, hinfo, params)
+	name.Idrr = idrr
+	path := fmt.Sprintf("%s/%d", restNamesPath, idrr)
+	verify := func() (bool, error) { return c.onPrimary().hostUpdated(fqdn, params) }
+	return c.restSubmit(http.MethodPut, path, &name, restForm("store", name), verify)
+}
+
+// REST implementation of DelHost. The name is deleted from the view of the `idview`
+// parameter (the default view when not given); a name declared in several views
+// whose view is not given by the API is not deleted.
+func (c *NetmagisClient) restDelHost(fqdn string, params map[string]interface{}) error {
+	names, err := c.restNames(fqdn)
+	if err != nil {
+		return err
+	}
+	idview, _ := strconv.Atoi(c.viewParam(params))
+	selected := []restName{}
+	for _, name := range names {
+		if name.Idview == 0 || name.Idview == idview {
+			selected = append(selected, name)
+		}
+	}
+	switch {
+	case len(selected) == 0:
+		return &NetmagisError{
+			msg:  fmt.Sprintf("NetmagisError: Name '%s' does not exist", fqdn),
+			kind: ErrNotFound,
+		}
+	case len(selected) > 1:
+		return &NetmagisError{
+			msg:  fmt.Sprintf("DelHost: '%s' is declared in %d views", fqdn, len(selected)),
+			kind: ErrAmbiguous,
+		}
+	}
+
+	path := fmt.Sprintf("%s/%d", restNamesPath, selected[0].Idrr)
+	form := url.Values{"name": {selected[0].Name}, "domain": {selected[0].Domain}}
+	verify := func() (bool, error) { return c.onPrimary().hostDeleted(fqdn) }
+	return c.restSubmit(http.MethodDelete, path, nil, form, verify)
+}
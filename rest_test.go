@@ -0,0 +1,119 @@
+package netmagis
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Handler of a REST API serving the names of `names` and storing the posted ones,
+// whose version resource answers with the statuses of `probes` (then 200).
+type restServer struct {
+	t       *testing.T
+	probes  []int
+	names   []restName
+	posted  []restName
+	lostAdd bool
+	html    int
+}
+
+func (s *restServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/version":
+		if len(s.probes) > 0 {
+			status := s.probes[0]
+			s.probes = s.probes[1:]
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"version": "3.0"}`))
+	case "/api/names":
+		if r.Method == http.MethodPost {
+			name := restName{}
+			json.NewDecoder(r.Body).Decode(&name)
+			s.posted = append(s.posted, name)
+			s.names = append(s.names, name)
+			if s.lostAdd {
+				// The name is stored but the response is lost
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		names := []restName{}
+		for _, name := range s.names {
+			if name.Name == r.URL.Query().Get("name") && name.Domain == r.URL.Query().Get("domain") {
+				names = append(names, name)
+			}
+		}
+		json.NewEncoder(w).Encode(names)
+	case "/mod":
+		s.html++
+		w.Write([]byte(fixture(s.t, "mod_host.html")))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newRESTTestClient(t *testing.T, server *restServer, opts ...ClientOption) *NetmagisClient {
+	client := newTestClient(t, server.ServeHTTP)
+	opts = append([]ClientOption{WithRESTAPI(client.BaseUrl+"/api", "token"), WithDefaultView(2)}, opts...)
+	return newTestClient(t, server.ServeHTTP, opts...)
+}
+
+func TestRESTProbeAuthFailure(t *testing.T) {
+	server := &restServer{t: t, probes: []int{http.StatusUnauthorized}}
+	client := newRESTTestClient(t, server)
+
+	_, err := client.GetHost("www.example.com")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected a permission error, got %v", err)
+	}
+	if server.html != 0 {
+		t.Errorf("unexpected fallback to the HTML interface")
+	}
+}
+
+func TestRESTProbeRetried(t *testing.T) {
+	server := &restServer{
+		t:      t,
+		probes: []int{http.StatusServiceUnavailable},
+		names:  []restName{{Idrr: 42, Name: "www", Domain: "example.com", Addresses: []string{"192.0.2.1"}}},
+	}
+	client := newRESTTestClient(t, server)
+
+	host, err := client.GetHost("www.example.com")
+	if err != nil {
+		t.Fatalf("first call: unexpected error: %s", err)
+	}
+	if server.html != 1 || host["idrr"] != 1234 {
+		t.Errorf("first call: expected the HTML interface, got %v", host)
+	}
+
+	host, err = client.GetHost("www.example.com")
+	if err != nil {
+		t.Fatalf("second call: unexpected error: %s", err)
+	}
+	if server.html != 1 || host["idrr"] != 42 {
+		t.Errorf("second call: expected the REST API, got %v", host)
+	}
+}
+
+func TestRESTAddHostRetryVerified(t *testing.T) {
+	server := &restServer{t: t, lostAdd: true}
+	client := newRESTTestClient(t, server, WithRetries(2, time.Millisecond))
+
+	if err := client.AddHost("new.example.com", "192.0.2.30", map[string]interface{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(server.posted) != 1 {
+		t.Fatalf("expected the name to be posted once, got %d", len(server.posted))
+	}
+	if server.posted[0].Idview != 2 {
+		t.Errorf("expected the default view 2, got %d", server.posted[0].Idview)
+	}
+}
@@ -0,0 +1,55 @@
+package netmagis
+
+import (
+	"net/url"
+	"regexp"
+)
+
+var (
+	// Non-fatal messages displayed by Netmagis alongside the success marker (e.g.
+	// a host added without its reverse record), in orange or prefixed by "Warning".
+	warningRegexp = regexp.MustCompile(
+		`(?is)<FONT COLOR="#FF8000">(.*?)</FONT>|\bwarning\s*:\s*([^<]+)`,
+	)
+	tagRegexp = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Result of a successful mutating operation, passed to the handler given with
+// WithResultHandler.
+type OperationResult struct {
+	// Form action (e.g. `add-host`)
+	Operation string
+	// Endpoint the form was submitted to
+	Uri string
+	// Warnings displayed by Netmagis, for reporting partial successes
+	Warnings []string
+}
+
+// Return the warnings found in a Netmagis response.
+func parseWarnings(body string) []string {
+	warnings := []string{}
+	for _, submatch := range warningRegexp.FindAllStringSubmatch(body, -1) {
+		warning := submatch[1]
+		if warning == "" {
+			warning = submatch[2]
+		}
+		if warning = cleanText(tagRegexp.ReplaceAllString(warning, "")); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// Pass the result of the operation submitted to `uri` to the result handler, if
+// any. `body` is empty when the operation was not submitted again because the
+// previous attempt succeeded (see submit).
+func (c *NetmagisClient) reportResult(uri string, formData url.Values, body string) {
+	if c.resultHandler == nil {
+		return
+	}
+	c.resultHandler(OperationResult{
+		Operation: formData.Get("action"),
+		Uri:       uri,
+		Warnings:  parseWarnings(body),
+	})
+}
@@ -0,0 +1,43 @@
+package netmagis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWarnings(t *testing.T) {
+	tests := map[string][]string{
+		"add_warning.html": {
+			"Reverse record for 192.0.2.20 not created: zone 2.0.192.in-addr.arpa not managed",
+			"no DHCP profile for this network",
+		},
+		"add_success.html": {},
+	}
+	for name, expected := range tests {
+		if warnings := parseWarnings(fixture(t, name)); !reflect.DeepEqual(warnings, expected) {
+			t.Errorf("%s: expected %q, got %q", name, expected, warnings)
+		}
+	}
+}
+
+func TestResultWarnings(t *testing.T) {
+	results := []OperationResult{}
+	client := newTestClient(
+		t,
+		fixtureHandler(t, map[string]string{
+			"/mod": "mod_notfound.html",
+			"/add": "add_warning.html",
+		}),
+		WithResultHandler(func(result OperationResult) { results = append(results, result) }),
+	)
+
+	if err := client.AddHost("new.example.com", "192.0.2.20", map[string]interface{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Operation != "add-host" || len(results[0].Warnings) != 2 {
+		t.Errorf("expected add-host with 2 warnings, got %+v", results[0])
+	}
+}
@@ -0,0 +1,34 @@
+package netmagis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default delay before the first retry.
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// Call `attempt` until it succeeds or fails with an error other than ErrTransport,
+// at most 1 + `retries` times (see WithRetries). The delay between attempts starts
+// at the backoff and doubles after each attempt.
+func (c *NetmagisClient) retry(attempt func() error) error {
+	backoff := c.retryBackoff
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil || retry >= c.retries || !errors.Is(err, ErrTransport) {
+			return err
+		}
+
+		select {
+		case <-c.clockOrDefault().After(backoff):
+		case <-c.context().Done():
+			return &NetmagisError{
+				msg:  fmt.Sprintf("%s (retries interrupted: %s)", err.Error(), c.context().Err()),
+				kind: ErrTransport,
+				err:  err,
+			}
+		}
+		backoff *= 2
+	}
+}
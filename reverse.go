@@ -0,0 +1,129 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Reverse zone managed by Netmagis.
+type ReverseZone struct {
+	Name string
+	// Network covered by the zone (e.g. 192.0.2.0/24 for 2.0.192.in-addr.arpa)
+	Cidr string
+	View string
+}
+
+// List the reverse zones (IPv4 and IPv6) from the zones administration pages.
+func (c *NetmagisClient) ListReverseZones() ([]ReverseZone, error) {
+	zones := []ReverseZone{}
+	for _, zoneType := range []string{"zone4", "zone6"} {
+		body, err := c.Call(
+			c.endpoints.AdmRef,
+			url.Values{"type": {zoneType}},
+			func(body string) bool { return true },
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := htmlquery.Parse(strings.NewReader(body))
+		if err != nil {
+			return nil, &NetmagisError{
+				msg: fmt.Sprintf("unable to parse /admref HTML response: %s", err.Error()),
+			}
+		}
+
+		for _, table := range htmlquery.Find(doc, "//table") {
+			for _, row := range parseTable(table) {
+				name := strings.TrimSuffix(row["name"], ".")
+				if !strings.HasSuffix(name, ".arpa") {
+					continue
+				}
+				zone := ReverseZone{Name: name, View: row["view"]}
+				for _, field := range []string{"selection", "prefix", "cidr", "network"} {
+					if _, network, err := net.ParseCIDR(row[field]); err == nil {
+						zone.Cidr = network.String()
+						break
+					}
+				}
+				if zone.Cidr == "" {
+					zone.Cidr = reverseZoneCidr(name)
+				}
+				zones = append(zones, zone)
+			}
+		}
+	}
+	return zones, nil
+}
+
+// Return the network covered by the reverse zone `name` (empty for classless or
+// invalid zone names).
+func reverseZoneCidr(name string) string {
+	name = strings.ToLower(name)
+	var labels []string
+	var ip net.IP
+	var bitsPerLabel int
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		labels = strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		ip, bitsPerLabel = make(net.IP, net.IPv4len), 8
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		labels = strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+		ip, bitsPerLabel = make(net.IP, net.IPv6len), 4
+	default:
+		return ""
+	}
+	if len(labels)*bitsPerLabel > len(ip)*8 {
+		return ""
+	}
+
+	// Labels are given from the least significant part
+	for idx, label := range labels {
+		position := len(labels) - 1 - idx
+		base := 10
+		if bitsPerLabel == 4 {
+			base = 16
+		}
+		value, err := strconv.ParseUint(label, base, bitsPerLabel)
+		if err != nil {
+			return ""
+		}
+		if bitsPerLabel == 8 {
+			ip[position] = byte(value)
+		} else {
+			ip[position/2] |= byte(value << (4 * (1 - position%2)))
+		}
+	}
+	return fmt.Sprintf("%s/%d", ip, len(labels)*bitsPerLabel)
+}
+
+// Return the most specific managed reverse zone containing `ip`, or nil when the
+// reverse of the address is not managed by Netmagis (in which case no PTR record
+// can be created).
+func (c *NetmagisClient) ReverseZoneFor(ip string) (*ReverseZone, error) {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("invalid IP address '%s'", ip)}
+	}
+	zones, err := c.ListReverseZones()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ReverseZone
+	bestSize := -1
+	for idx, zone := range zones {
+		_, network, err := net.ParseCIDR(zone.Cidr)
+		if err != nil || !network.Contains(parsedIp) {
+			continue
+		}
+		if size, _ := network.Mask.Size(); size > bestSize {
+			result, bestSize = &zones[idx], size
+		}
+	}
+	return result, nil
+}
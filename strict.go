@@ -0,0 +1,74 @@
+package netmagis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check the DNS rules of adding the alias `cname` to `data` in strict mode (see
+// WithStrictDNS): `cname` has no record and `data` is an existing host.
+func (c *NetmagisClient) checkAliasRules(cname string, data string) error {
+	if !c.strictDNS {
+		return nil
+	}
+	types, err := c.GetRecordTypes(cname)
+	if err != nil {
+		return err
+	}
+	if len(types) > 0 {
+		return &NetmagisError{
+			msg: fmt.Sprintf(
+				"alias '%s' not allowed: the name already has %s records", cname, strings.Join(types, ", "),
+			),
+			kind: ErrDNSRule,
+		}
+	}
+	return c.checkTargetRules("CNAME", data)
+}
+
+// Check the DNS rules of adding an address to `fqdn` in strict mode (see
+// WithStrictDNS): `fqdn` is not an alias.
+func (c *NetmagisClient) checkHostRules(fqdn string) error {
+	if !c.strictDNS {
+		return nil
+	}
+	types, err := c.GetRecordTypes(fqdn)
+	if err != nil {
+		return err
+	}
+	for _, recordType := range types {
+		if recordType == "CNAME" {
+			return &NetmagisError{
+				msg:  fmt.Sprintf("address not allowed on '%s': the name is an alias", fqdn),
+				kind: ErrDNSRule,
+			}
+		}
+	}
+	return nil
+}
+
+// Check that the target of a record of type `recordType` (CNAME, MX, SRV) is an
+// existing host, in strict mode (see WithStrictDNS). An alias is not a valid target
+// (RFC 2181 for MX and SRV, and aliases chains are refused by Netmagis).
+func (c *NetmagisClient) checkTargetRules(recordType string, target string) error {
+	if !c.strictDNS {
+		return nil
+	}
+	host, err := c.Search(target)
+	if err != nil {
+		return err
+	}
+	if host == nil {
+		return &NetmagisError{
+			msg:  fmt.Sprintf("%s target '%s' does not exist", recordType, target),
+			kind: ErrDNSRule,
+		}
+	}
+	if host["is_alias"].(bool) {
+		return &NetmagisError{
+			msg:  fmt.Sprintf("%s target '%s' is an alias", recordType, target),
+			kind: ErrDNSRule,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package netmagis
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSanitizeBody(t *testing.T) {
+	client := newTestClient(t, nil)
+	tests := map[string]string{
+		"Café":          "Café",
+		"Caf\xe9":       "Caf\uFFFD",
+		"\xff\xfeplain": "\uFFFDplain",
+	}
+	for body, expected := range tests {
+		sanitized, err := client.sanitizeBody("/search", []byte(body))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", body, err)
+			continue
+		}
+		if string(sanitized) != expected {
+			t.Errorf("%q: expected %q, got %q", body, expected, sanitized)
+		}
+	}
+}
+
+func TestInvalidUTF8Comment(t *testing.T) {
+	client := newTestClient(t, fixtureHandler(t, map[string]string{"/search": "search_invalid_utf8.html"}))
+
+	host, err := client.Search("www.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if comment := host["comment"]; comment != "Caf\uFFFD web server" {
+		t.Errorf("expected the invalid byte replaced, got %q", comment)
+	}
+	if _, err := json.Marshal(host); err != nil {
+		t.Errorf("unable to encode the host: %s", err)
+	}
+}
+
+func TestStrictUTF8(t *testing.T) {
+	client := newTestClient(
+		t, fixtureHandler(t, map[string]string{"/search": "search_invalid_utf8.html"}), WithStrictUTF8(),
+	)
+	if _, err := client.Search("www.example.com"); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+
+	client = newTestClient(
+		t, fixtureHandler(t, map[string]string{"/search": "search_host.html"}), WithStrictUTF8(),
+	)
+	if _, err := client.Search("www.example.com"); err != nil {
+		t.Errorf("valid page: unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,47 @@
+package netmagis
+
+import (
+	"strings"
+)
+
+// Identity of the user owning the session.
+type User struct {
+	Login  string
+	Name   string
+	Groups []string
+	// Whether the user has Netmagis administrator rights
+	Admin bool
+}
+
+// Return the first non-empty value among the given fields of `info`.
+func firstField(info map[string]string, fields ...string) string {
+	for _, field := range fields {
+		if value := info[field]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Return the identity of the logged-in user (login, name, groups and rights),
+// scraped from the profile page (see UserInfo).
+func (c *NetmagisClient) WhoAmI() (*User, error) {
+	info, err := c.UserInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		Login: firstField(info, "login", "user", "username"),
+		Name:  firstField(info, "name", "full name", "fullname"),
+		Groups: strings.FieldsFunc(firstField(info, "groups", "group"), func(r rune) bool {
+			return r == ',' || r == ' '
+		}),
+	}
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(info["first name"] + " " + info["last name"])
+	}
+	rights := strings.ToLower(firstField(info, "administrator", "admin", "rights"))
+	user.Admin = rights == "yes" || strings.Contains(rights, "admin")
+	return user, nil
+}
@@ -0,0 +1,169 @@
+package netmagis
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Host fields set through the /mod form, compared when verifying an update.
+var hostFormFields = []string{
+	"ttl", "mac", "iddhcpprof", "hinfo", "comment", "respname", "respmail", "sendsmtp",
+}
+
+// Submit a mutating form (see submitRequest). Add and delete forms may be answered
+// with intermediate forms, which are followed until the success page (see
+// callSteps). The double-submit CSRF cookies are echoed as headers (see
+// withCSRFHeaders).
+func (c *NetmagisClient) submit(uri string, formData url.Values, checkFunc func(body string) bool, verify func() (bool, error)) error {
+	client := c.onPrimary().withCSRFHeaders(uri)
+	send := func() (string, error) {
+		if uri == c.endpoints.Add || uri == c.endpoints.Del {
+			return client.callSteps(uri, formData, checkFunc)
+		}
+		return client.call(uri, formData, checkFunc)
+	}
+	return c.submitRequest(uri, formData, send, verify)
+}
+
+// Send a mutating request with `send` (a form submission or a REST API call, whose
+// parameters are given by `formData`) and return its error. In lenient mode (see
+// WithLenientValidation), a response without error page but missing the success
+// marker is accepted when `verify` confirms the expected state. With
+// WithVerifyAfterWrite, `verify` is always run after a successful submission.
+// `verify` must read from the primary instance (see onPrimary), a replica possibly
+// not showing the write yet.
+//
+// HTTP errors are retried according to WithRetries but, as submissions are not
+// idempotent, `verify` is called before each new attempt and the submission is
+// not repeated when the previous attempt actually succeeded.
+//
+// The submission and its outcome are reported to the audit handler (see
+// WithAuditHandler).
+func (c *NetmagisClient) submitRequest(uri string, formData url.Values, send func() (string, error), verify func() (bool, error)) error {
+	c.markWrite()
+	err := c.onPrimary().sendWrite(uri, formData, send, verify)
+	c.markWrite()
+	c.audit(uri, formData, err)
+	return err
+}
+
+func (c *NetmagisClient) sendWrite(uri string, formData url.Values, send func() (string, error), verify func() (bool, error)) error {
+	attempt, body := 0, ""
+	err := c.retry(func() error {
+		attempt++
+		if attempt > 1 {
+			if verified, verifyErr := verify(); verifyErr == nil && verified {
+				return nil
+			}
+		}
+		var err error
+		body, err = send()
+		return err
+	})
+	if err != nil {
+		if !c.lenient || !errors.Is(err, ErrValidation) {
+			return err
+		}
+
+		verified, verifyErr := verify()
+		if verifyErr != nil {
+			return &NetmagisError{
+				msg: fmt.Sprintf(
+					"%s (verification read failed: %s)", err.Error(), verifyErr.Error(),
+				),
+				kind: ErrValidation,
+			}
+		}
+		if !verified {
+			return err
+		}
+		c.reportResult(uri, formData, body)
+		return nil
+	}
+
+	if c.verifyAfterWrite {
+		verified, verifyErr := verify()
+		if verifyErr != nil {
+			return &NetmagisError{
+				msg:  fmt.Sprintf("verification read failed: %s", verifyErr.Error()),
+				kind: ErrVerificationFailed,
+			}
+		}
+		if !verified {
+			return &NetmagisError{
+				msg:  fmt.Sprintf("%s: state read back does not match the change", uri),
+				kind: ErrVerificationFailed,
+			}
+		}
+	}
+	c.reportResult(uri, formData, body)
+	return nil
+}
+
+// Check that the host `fqdn` exists with the address `ip`.
+func (c *NetmagisClient) hostAdded(fqdn string, ip string) (bool, error) {
+	host, err := c.Search(fqdn)
+	if err != nil || host == nil {
+		return false, err
+	}
+	addresses := searchValues(host, "ip_addresses", "ip_address")
+	if len(addresses) == 0 {
+		// Addresses not displayed, the name existence is the only check possible
+		return true, nil
+	}
+	for _, address := range addresses {
+		if address == ip {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Check that the form fields of the host `fqdn` match `params`.
+func (c *NetmagisClient) hostUpdated(fqdn string, params map[string]interface{}) (bool, error) {
+	current, err := c.GetHost(fqdn)
+	if err != nil || current == nil {
+		return false, err
+	}
+	desired := Host{}
+	for _, field := range hostFormFields {
+		if value, found := params[field]; found {
+			desired[field] = value
+		}
+	}
+	return len(HostDiff(current, desired)) == 0, nil
+}
+
+// Check that nothing is declared anymore for `fqdn`.
+func (c *NetmagisClient) hostDeleted(fqdn string) (bool, error) {
+	host, err := c.Search(fqdn)
+	if err != nil {
+		return false, err
+	}
+	return host == nil, nil
+}
+
+// Check that the alias `cname` points to `data`.
+func (c *NetmagisClient) aliasAdded(cname string, data string) (bool, error) {
+	target, err := c.GetAlias(cname)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(target, data), nil
+}
+
+// Check that `fqdn` has a record of type `recordType` whose value mentions `value`.
+func (c *NetmagisClient) recordExists(fqdn string, recordType string, value string) (bool, error) {
+	records, err := c.GetRecords(fqdn)
+	if err != nil {
+		return false, err
+	}
+	for _, record := range records {
+		if record.Type == recordType && strings.Contains(record.Value, value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
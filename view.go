@@ -0,0 +1,69 @@
+package netmagis
+
+import (
+	"fmt"
+	"github.com/antchfx/htmlquery"
+	"net/url"
+)
+
+// View used when the server default view can't be determined (the first view of a
+// Netmagis instance).
+const fallbackView = "1"
+
+// Return the id of the default view, used by the operations when no `idview`
+// parameter is given (see WithDefaultView).
+func (c *NetmagisClient) DefaultView() string {
+	if c.defaultView == "" {
+		return fallbackView
+	}
+	return c.defaultView
+}
+
+// Retrieve the default view of the server, unless set with WithDefaultView: the
+// view selected (or checked) by default in the /add form. It is kept unset when
+// the form has no view field (instances with a single view), the fallback view
+// being used then. An error is returned when the form can't be read or selects no
+// view, rather than guessing one of the views.
+func (c *NetmagisClient) resolveDefaultView() error {
+	if c.defaultView != "" {
+		return nil
+	}
+	// The form is kept in the form cache, sparing its discovery on the first add
+	doc, _, err := c.loadForm(c.endpoints.Add, url.Values{})
+	if err != nil {
+		return &NetmagisError{
+			msg: fmt.Sprintf("unable to retrieve the default view (set it with WithDefaultView): %s", err.Error()),
+			err: err,
+		}
+	}
+
+	if htmlquery.FindOne(doc, "//select[@name='idview'] | //input[@name='idviews']") == nil {
+		return nil
+	}
+	for _, xpath := range []string{
+		"//select[@name='idview']/option[@selected]",
+		"//input[@name='idviews' and @checked]",
+	} {
+		if node := htmlquery.FindOne(doc, xpath); node != nil {
+			if view := htmlquery.SelectAttr(node, "value"); view != "" {
+				c.defaultView = view
+				return nil
+			}
+		}
+	}
+	return &NetmagisError{
+		msg: fmt.Sprintf(
+			"no view selected by default in the %s form (set it with WithDefaultView)",
+			c.endpoints.Add,
+		),
+		kind: ErrValidation,
+	}
+}
+
+// Return the view of an operation: the `idview` parameter, or the default view.
+func (c *NetmagisClient) viewParam(params map[string]interface{}) string {
+	if view := intToStr(try(params, "idview", "")); view != "" {
+		return view
+	}
+	return c.DefaultView()
+}
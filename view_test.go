@@ -0,0 +1,56 @@
+package netmagis
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestResolveDefaultView(t *testing.T) {
+	tests := []struct {
+		form     string
+		expected string
+		err      error
+	}{
+		{"add_form.html", "1", nil},
+		{"add_views_checked.html", "2", nil},
+		{"add_noview.html", fallbackView, nil},
+		{"add_views_unselected.html", "", ErrValidation},
+	}
+	for _, test := range tests {
+		client := newTestClient(t, fixtureHandler(t, map[string]string{"/add": test.form}))
+		err := client.resolveDefaultView()
+		switch {
+		case test.err == nil && err != nil:
+			t.Errorf("%q: unexpected error: %s", test.form, err)
+		case test.err != nil && !errors.Is(err, test.err):
+			t.Errorf("%q: expected %v, got %v", test.form, test.err, err)
+		case test.err == nil && client.DefaultView() != test.expected:
+			t.Errorf("%q: expected view %q, got %q", test.form, test.expected, client.DefaultView())
+		}
+	}
+}
+
+func TestResolveDefaultViewUnreachable(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	})
+	if err := client.resolveDefaultView(); !errors.Is(err, ErrTransport) {
+		t.Fatalf("expected a transport error, got %v", err)
+	}
+	if client.defaultView != "" {
+		t.Errorf("unexpected default view %q", client.defaultView)
+	}
+}
+
+func TestResolveDefaultViewOption(t *testing.T) {
+	// The form is not read when the view is given
+	client := newTestClient(t, fixtureHandler(t, map[string]string{}), WithDefaultView(3))
+	if err := client.resolveDefaultView(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if client.DefaultView() != "3" {
+		t.Errorf("expected view 3, got %q", client.DefaultView())
+	}
+}
@@ -0,0 +1,65 @@
+package netmagis
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Delays between the polls of WaitForHost
+	initialWaitDelay = 500 * time.Millisecond
+	maxWaitDelay     = 10 * time.Second
+)
+
+// Wait until the host `fqdn` is visible through Search, for deployments where
+// writes are not immediately visible (caching, replication). The search is
+// polled with an increasing delay until the host appears or `timeout` elapses.
+func (c *NetmagisClient) WaitForHost(fqdn string, timeout time.Duration) error {
+	return c.waitFor(fqdn, true, timeout)
+}
+
+// Same as WaitForHost, but wait until the host is no longer visible (e.g. after
+// DelHost).
+func (c *NetmagisClient) WaitForHostGone(fqdn string, timeout time.Duration) error {
+	return c.waitFor(fqdn, false, timeout)
+}
+
+func (c *NetmagisClient) waitFor(fqdn string, present bool, timeout time.Duration) error {
+	clock := c.clockOrDefault()
+	deadline := clock.Now().Add(timeout)
+	delay := initialWaitDelay
+	for {
+		host, err := c.Search(fqdn)
+		if err != nil {
+			return err
+		}
+		if (host != nil) == present {
+			return nil
+		}
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			state := "appear"
+			if !present {
+				state = "disappear"
+			}
+			return &NetmagisError{
+				msg: fmt.Sprintf("host '%s' did not %s within %s", fqdn, state, timeout),
+			}
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+
+		select {
+		case <-clock.After(delay):
+		case <-c.context().Done():
+			return &NetmagisError{
+				msg: fmt.Sprintf("waiting for host '%s' interrupted: %s", fqdn, c.context().Err()),
+			}
+		}
+		if delay *= 2; delay > maxWaitDelay {
+			delay = maxWaitDelay
+		}
+	}
+}
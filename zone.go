@@ -0,0 +1,417 @@
+package netmagis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Serialization format of zone records (see ExportZone and RegisterZoneFormat).
+type ZoneFormat interface {
+	Marshal(domain string, records []Record) ([]byte, error)
+}
+
+// Zone format which can also be parsed, for importing records (see ImportZone).
+type ZoneParser interface {
+	Unmarshal(data []byte) ([]Record, error)
+}
+
+var (
+	zoneFormatsMutex sync.RWMutex
+	zoneFormats      = map[string]ZoneFormat{
+		"json": JSONZoneFormat{},
+		"bind": BindZoneFormat{},
+	}
+)
+
+// Register a zone format under `name`, replacing any format with the same name.
+func RegisterZoneFormat(name string, format ZoneFormat) {
+	zoneFormatsMutex.Lock()
+	defer zoneFormatsMutex.Unlock()
+	zoneFormats[name] = format
+}
+
+func getZoneFormat(name string) (ZoneFormat, error) {
+	zoneFormatsMutex.RLock()
+	defer zoneFormatsMutex.RUnlock()
+	format, found := zoneFormats[name]
+	if !found {
+		return nil, &NetmagisError{msg: fmt.Sprintf("unknown zone format '%s'", name)}
+	}
+	return format, nil
+}
+
+// Zone records as a JSON array of Record.
+type JSONZoneFormat struct{}
+
+func (JSONZoneFormat) Marshal(domain string, records []Record) ([]byte, error) {
+	return json.MarshalIndent(records, "", "  ")
+}
+
+func (JSONZoneFormat) Unmarshal(data []byte) ([]Record, error) {
+	records := []Record{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("invalid JSON zone: %s", err.Error())}
+	}
+	for idx := range records {
+		records[idx].Name = strings.TrimSuffix(records[idx].Name, ".")
+		records[idx].Type = strings.ToUpper(records[idx].Type)
+	}
+	return records, nil
+}
+
+// Zone records in BIND zonefile format (without SOA and NS records, which are not
+// managed through Netmagis).
+type BindZoneFormat struct{}
+
+func (BindZoneFormat) Marshal(domain string, records []Record) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "$ORIGIN %s.\n", strings.Trim(domain, "."))
+	for _, record := range records {
+		name := bindOwnerName(record.Name, domain)
+		ttl := ""
+		if record.TTL > 0 {
+			ttl = fmt.Sprint(record.TTL)
+		}
+		value := record.Value
+		if record.Type == "CNAME" || ((record.Type == "MX" || record.Type == "SRV") && checkFqdn(lastField(value))) {
+			value += "."
+		}
+		fmt.Fprintf(buffer, "%s\t%s\tIN\t%s\t%s\n", name, ttl, record.Type, value)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Return the owner name of a record in a zonefile of `domain`: relative to the
+// zone when the name is in it, fully qualified otherwise (e.g. an alias of
+// another domain pointing to a host of the zone).
+func bindOwnerName(name string, domain string) string {
+	name, domain = strings.TrimSuffix(name, "."), strings.Trim(domain, ".")
+	switch {
+	case name == domain:
+		return "@"
+	case strings.HasSuffix(name, "."+domain):
+		return strings.TrimSuffix(name, "."+domain)
+	default:
+		return name + "."
+	}
+}
+
+// Parse a BIND zonefile. $ORIGIN and $TTL directives, relative names, `@` and
+// blank owner names are handled; names are returned without trailing dot. All
+// record types are returned, including those not manageable through Netmagis (SOA,
+// NS, TXT, ...).
+func (BindZoneFormat) Unmarshal(data []byte) ([]Record, error) {
+	records := []Record{}
+	origin, owner := "", ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := stripZoneComment(scanner.Text())
+		// Join records spanning several lines (e.g. SOA)
+		for strings.Count(line, "(") > strings.Count(line, ")") && scanner.Scan() {
+			lineNumber++
+			line += " " + stripZoneComment(scanner.Text())
+		}
+		line = strings.NewReplacer("(", " ", ")", " ").Replace(line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		lineError := func(msg string) error {
+			return &NetmagisError{msg: fmt.Sprintf("zonefile line %d: %s", lineNumber, msg)}
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if len(fields) < 2 {
+				return nil, lineError("$ORIGIN without domain")
+			}
+			origin = strings.TrimSuffix(fields[1], ".")
+			continue
+		case "$TTL":
+			continue
+		case "$INCLUDE":
+			return nil, lineError("$INCLUDE is not supported")
+		}
+
+		// A line starting with a blank reuses the previous owner name
+		if line[0] != ' ' && line[0] != '\t' {
+			owner = absoluteName(fields[0], origin)
+			fields = fields[1:]
+		}
+		if owner == "" {
+			return nil, lineError("record without owner name")
+		}
+
+		record := Record{Name: owner}
+		for len(fields) > 0 {
+			if ttl, err := strconv.Atoi(fields[0]); err == nil {
+				record.TTL = ttl
+			} else if strings.ToUpper(fields[0]) != "IN" {
+				break
+			}
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			return nil, lineError("missing record type or data")
+		}
+		record.Type, fields = strings.ToUpper(fields[0]), fields[1:]
+
+		switch record.Type {
+		case "CNAME":
+			fields[0] = absoluteName(fields[0], origin)
+		case "MX":
+			if len(fields) != 2 {
+				return nil, lineError("invalid MX data (expected: priority target)")
+			}
+			fields[1] = absoluteName(fields[1], origin)
+		case "SRV":
+			if len(fields) != 4 {
+				return nil, lineError("invalid SRV data (expected: priority weight port target)")
+			}
+			fields[3] = absoluteName(fields[3], origin)
+		}
+		record.Value = strings.Join(fields, " ")
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("unable to read zonefile: %s", err.Error())}
+	}
+	return records, nil
+}
+
+// Remove the comment (starting with `;`) of a zonefile line.
+func stripZoneComment(line string) string {
+	if idx := strings.Index(line, ";"); idx != -1 {
+		return line[:idx]
+	}
+	return line
+}
+
+// Return the absolute form of the zonefile name `name`, without trailing dot.
+func absoluteName(name string, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case origin == "":
+		return name
+	}
+	return name + "." + origin
+}
+
+// Return the last whitespace-separated field of `value`.
+func lastField(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// Enumerate the records of the zone `domain`: the records of each host of the
+// zone found in the networks the user can consult (see ListHosts) and the aliases
+// pointing to them.
+func (c *NetmagisClient) ZoneRecords(domain string) ([]Record, error) {
+	hosts, err := c.ListHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, host := range hosts {
+		name, _ := host["name"].(string)
+		if strings.HasSuffix(name, "."+strings.Trim(domain, ".")) {
+			names[name] = true
+		}
+	}
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	records := []Record{}
+	for _, name := range sortedNames {
+		host, err := c.Search(name)
+		if err != nil {
+			return nil, err
+		}
+		if host == nil {
+			continue
+		}
+		records = append(records, searchRecords(name, host)...)
+		for _, alias := range searchValues(host, "aliases") {
+			records = append(records, Record{Name: alias, Type: "CNAME", Value: name})
+		}
+	}
+	return records, nil
+}
+
+// Export the records of the zone `domain` (see ZoneRecords) in the given format
+// ("json", "bind" or any format registered with RegisterZoneFormat).
+func (c *NetmagisClient) ExportZone(domain string, format string) ([]byte, error) {
+	zoneFormat, err := getZoneFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	records, err := c.ZoneRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	return zoneFormat.Marshal(domain, records)
+}
+
+// Outcome of the import of a record (see ImportZone).
+type ImportAction string
+
+const (
+	// The record was created (would be created in dry-run mode).
+	ImportCreated ImportAction = "created"
+	// The record already exists and was left untouched.
+	ImportExisting ImportAction = "existing"
+	// The record type can't be managed through Netmagis (SOA, NS, TXT, ...).
+	ImportSkipped ImportAction = "skipped"
+	// The record could not be checked or created (see Err).
+	ImportFailed ImportAction = "failed"
+)
+
+// Result of the import of a record.
+type ImportResult struct {
+	Record Record
+	Action ImportAction
+	Err    error
+}
+
+// Import the records read from `r` in the given format ("json", "bind" or any
+// registered format implementing ZoneParser), creating the missing ones with
+// AddRecord. Addresses are created first, so aliases, mail exchangers and services
+// can point to them. With `dryRun`, nothing is created and the result reports what
+// would change. A record failing does not stop the import: the error is reported
+// in its result, and the returned error is only set when the input can't be read
+// or parsed.
+func (c *NetmagisClient) ImportZone(r io.Reader, format string, dryRun bool) ([]ImportResult, error) {
+	zoneFormat, err := getZoneFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	parser, ok := zoneFormat.(ZoneParser)
+	if !ok {
+		return nil, &NetmagisError{msg: fmt.Sprintf("zone format '%s' can't be parsed", format)}
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, &NetmagisError{msg: fmt.Sprintf("unable to read zone: %s", err.Error())}
+	}
+	records, err := parser.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return isAddressRecord(records[i]) && !isAddressRecord(records[j])
+	})
+
+	results := []ImportResult{}
+	for _, record := range records {
+		result := ImportResult{Record: record}
+		result.Action, result.Err = c.importRecord(record, dryRun)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func isAddressRecord(record Record) bool {
+	return record.Type == "A" || record.Type == "AAAA"
+}
+
+// Create `record` unless it already exists, and return the resulting action.
+func (c *NetmagisClient) importRecord(record Record, dryRun bool) (ImportAction, error) {
+	rdata := map[string]string{}
+	fields := strings.Fields(record.Value)
+	switch {
+	case isAddressRecord(record) && len(fields) == 1:
+		rdata["ip"] = fields[0]
+	case record.Type == "CNAME" && len(fields) == 1:
+		rdata["target"] = fields[0]
+	case record.Type == "MX" && len(fields) == 2:
+		rdata["priority"], rdata["target"] = fields[0], strings.TrimSuffix(fields[1], ".")
+	case record.Type == "SRV" && len(fields) == 4:
+		rdata["priority"], rdata["weight"], rdata["port"] = fields[0], fields[1], fields[2]
+		rdata["target"] = strings.TrimSuffix(fields[3], ".")
+	case record.Type == "A", record.Type == "AAAA", record.Type == "CNAME",
+		record.Type == "MX", record.Type == "SRV":
+		return ImportFailed, &NetmagisError{
+			msg: fmt.Sprintf("invalid %s record data '%s'", record.Type, record.Value),
+		}
+	default:
+		return ImportSkipped, nil
+	}
+
+	exists, err := c.recordExists(record.Name, record.Type, lastField(record.Value))
+	if err != nil {
+		return ImportFailed, err
+	}
+	if exists {
+		return ImportExisting, nil
+	}
+	if !dryRun {
+		if err := c.AddRecord(record.Name, record.Type, rdata); err != nil {
+			return ImportFailed, err
+		}
+	}
+	return ImportCreated, nil
+}
+
+// Set the TTL of all the hosts of the zone `domain` found in the networks the user
+// can consult (see ListHosts), e.g. for lowering TTLs ahead of a migration. Hosts
+// already having the TTL are left untouched, so an interrupted run can be resumed
+// by calling it again. All the hosts are processed even if some fail. Consider
+// WithRateLimit for large zones.
+func (c *NetmagisClient) SetZoneTTL(domain string, ttl int) ([]BatchResult, error) {
+	hosts, err := c.ListHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	domain = normalizeFqdn(domain)
+	names := map[string]bool{}
+	for _, host := range hosts {
+		name, _ := host["name"].(string)
+		if strings.HasSuffix(normalizeFqdn(name), "."+domain) {
+			names[normalizeFqdn(name)] = true
+		}
+	}
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	return c.runBatch(sortedNames, func(client *NetmagisClient, idx int, name string) error {
+		host, err := client.GetHost(name)
+		if err != nil {
+			return err
+		}
+		if host == nil {
+			return &NetmagisError{msg: fmt.Sprintf("host '%s' does not exist", name), kind: ErrNotFound}
+		}
+		if normalizeHostValue(host["ttl"]) == strconv.Itoa(ttl) {
+			return nil
+		}
+		idrr, err := hostIdrr(host)
+		if err != nil {
+			return err
+		}
+		host["ttl"] = ttl
+		return client.UpdateHost(name, idrr, host)
+	})
+}
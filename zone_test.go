@@ -0,0 +1,132 @@
+package netmagis
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBindZoneFormatMarshal(t *testing.T) {
+	records := []Record{
+		{Name: "example.com", Type: "MX", Value: "10 mail.example.com"},
+		{Name: "www.example.com", Type: "A", Value: "192.0.2.1", TTL: 3600},
+		{Name: "web.example.com", Type: "CNAME", Value: "www.example.com"},
+		{Name: "www.example.org", Type: "CNAME", Value: "www.example.com"},
+	}
+	data, err := BindZoneFormat{}.Marshal("example.com", records)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.com.",
+		"@\t\tIN\tMX\t10 mail.example.com.",
+		"www\t3600\tIN\tA\t192.0.2.1",
+		"web\t\tIN\tCNAME\twww.example.com.",
+		"www.example.org.\t\tIN\tCNAME\twww.example.com.",
+		"",
+	}, "\n")
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	parsed, err := BindZoneFormat{}.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(parsed) != len(records) {
+		t.Fatalf("expected %d records, got %d: %v", len(records), len(parsed), parsed)
+	}
+	for idx, record := range records {
+		if parsed[idx].Name != record.Name || parsed[idx].Type != record.Type {
+			t.Errorf("record %d: expected %s %s, got %s %s",
+				idx, record.Name, record.Type, parsed[idx].Name, parsed[idx].Type)
+		}
+	}
+}
+
+// Zone with an existing address, a new service and a record Netmagis can't manage
+const importZone = `$ORIGIN example.com.
+$TTL 3600
+www		IN	A	192.0.2.1
+_sip._tcp	3600	IN	SRV	10 5 5060 sip.example.com.
+@		IN	TXT	"v=spf1 -all"
+`
+
+// Return a handler of the zone import, answering the searches of the names of
+// `searches` with their fixture (not found otherwise) and recording the submissions.
+func importHandler(t *testing.T, searches map[string]string, submitted *[]url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/search":
+			name, found := searches[r.Form.Get("q")]
+			if !found {
+				name = "search_notfound.html"
+			}
+			w.Write([]byte(fixture(t, name)))
+		case r.URL.Path == "/add" && r.PostForm.Get("action") != "":
+			*submitted = append(*submitted, r.PostForm)
+			w.Write([]byte(fixture(t, "add_srv_success.html")))
+		default:
+			w.Write([]byte("<html></html>"))
+		}
+	}
+}
+
+func TestImportZone(t *testing.T) {
+	for _, dryRun := range []bool{true, false} {
+		submitted := []url.Values{}
+		client := newTestClient(t, importHandler(t, map[string]string{
+			"www.example.com": "search_host.html",
+		}, &submitted))
+
+		results, err := client.ImportZone(strings.NewReader(importZone), "bind", dryRun)
+		if err != nil {
+			t.Fatalf("dry run %t: unexpected error: %s", dryRun, err)
+		}
+		expected := map[string]ImportAction{
+			"www.example.com/A":         ImportExisting,
+			"_sip._tcp.example.com/SRV": ImportCreated,
+			"example.com/TXT":           ImportSkipped,
+		}
+		if len(results) != len(expected) {
+			t.Fatalf("dry run %t: expected %d results, got %v", dryRun, len(expected), results)
+		}
+		for _, result := range results {
+			key := result.Record.Name + "/" + result.Record.Type
+			if result.Action != expected[key] || result.Err != nil {
+				t.Errorf("dry run %t: %s: expected %s, got %s (%v)", dryRun, key, expected[key], result.Action, result.Err)
+			}
+		}
+
+		switch {
+		case dryRun && len(submitted) != 0:
+			t.Errorf("dry run: unexpected submissions: %v", submitted)
+		case !dryRun && (len(submitted) != 1 || submitted[0].Get("action") != "add-srv"):
+			t.Errorf("expected the service to be submitted, got %v", submitted)
+		}
+	}
+}
+
+func TestImportZoneExistingSRV(t *testing.T) {
+	submitted := []url.Values{}
+	client := newTestClient(t, importHandler(t, map[string]string{
+		"www.example.com":       "search_host.html",
+		"_sip._tcp.example.com": "search_srv.html",
+	}, &submitted))
+
+	results, err := client.ImportZone(strings.NewReader(importZone), "bind", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, result := range results {
+		if result.Record.Type == "SRV" && result.Action != ImportExisting {
+			t.Errorf("expected the service to exist, got %s (%v)", result.Action, result.Err)
+		}
+	}
+	if len(submitted) != 0 {
+		t.Errorf("unexpected submissions: %v", submitted)
+	}
+}